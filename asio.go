@@ -1,70 +1,6 @@
 package asio
 
-import (
-	"bytes"
-	"syscall"
-	"unsafe"
-)
-
-/*
-#include <string.h>
-
-typedef long ASIOBool;
-typedef double ASIOSampleRate;
-
-typedef struct ASIOSamples {
-	unsigned long hi;
-	unsigned long lo;
-} ASIOSamples;
-
-typedef struct ASIOTimeStamp {
-	unsigned long hi;
-	unsigned long lo;
-} ASIOTimeStamp;
-
-typedef struct ASIOTimeCode
-{
-	double          speed;                  // speed relation (fraction of nominal speed)
-	                                        // optional; set to 0. or 1. if not supported
-	ASIOSamples     timeCodeSamples;        // time in samples
-	unsigned long   flags;                  // some information flags (see below)
-	char future[64];
-} ASIOTimeCode;
-
-typedef struct AsioTimeInfo
-{
-	double          speed;                  // absolute speed (1. = nominal)
-	ASIOTimeStamp   systemTime;             // system time related to samplePosition, in nanoseconds
-	                                        // on mac, must be derived from Microseconds() (not UpTime()!)
-	                                        // on windows, must be derived from timeGetTime()
-	ASIOSamples     samplePosition;
-	ASIOSampleRate  sampleRate;             // current rate
-	unsigned long flags;                    // (see below)
-	char reserved[12];
-} AsioTimeInfo;
-
-typedef struct ASIOTime                          // both input/output
-{
-	long reserved[4];                       // must be 0
-	struct AsioTimeInfo     timeInfo;       // required
-	struct ASIOTimeCode     timeCode;       // optional, evaluated if (timeCode.flags & kTcValid)
-} ASIOTime;
-
-
-// Callback function pointer typedefs:
-typedef void (*bufferSwitch) (long doubleBufferIndex, ASIOBool directProcess);
-typedef void (*sampleRateDidChange) (ASIOSampleRate sRate);
-typedef long (*asioMessage) (long selector, long value, void* message, double* opt);
-typedef ASIOTime* (*bufferSwitchTimeInfo) (ASIOTime* params, long doubleBufferIndex, ASIOBool directProcess);
-
-// Pointer to Go function impl:
-void onBufferSwitch(long doubleBufferIndex, ASIOBool directProcess);
-void onSampleRateDidChange(ASIOSampleRate sRate);
-long onASIOMessage(long selector, long value, void* message, double* opt);
-ASIOTime* onBufferSwitchTimeInfo(ASIOTime* params, long doubleBufferIndex, ASIOBool directProcess);
-
-*/
-import "C"
+import "fmt"
 
 // Special ASIO error values:
 const (
@@ -83,7 +19,20 @@ const (
 	ASE_NoMemory                        // not enough memory for completing the request
 )
 
+// Error is an error returned by an ASIO driver.
+//
+// Errors match the fixed instance of their code with errors.Is, whichever call
+// or driver they come from, so that for example a malfunction can be told apart
+// from missing hardware:
+//
+//	if errors.Is(err, asio.ErrorHWMalfunction) { ... }
+//
+// Use errors.As to get at the call, the driver and the raw code.
 type Error struct {
+	Op     string // driver call that failed, such as "CreateBuffers"; empty for the fixed instances
+	Driver string // name of the driver
+	Detail string // the driver's own message, from getErrorMessage
+
 	errno int32
 	msg   string
 }
@@ -110,25 +59,69 @@ var knownErrors map[int32]*Error = map[int32]*Error{
 	ASE_NoMemory:         ErrorNoMemory,
 }
 
-func (drv *IASIO) asError(ase uintptr) *Error {
-	errno := int32(ase)
+// newError returns the error of a failed driver call. Codes outside of the
+// known ones are kept as they are, described by the driver's message if any.
+func newError(code int32, op, driver, detail string) *Error {
+	err := &Error{Op: op, Driver: driver, Detail: detail, errno: code}
+	if known, ok := knownErrors[code]; ok {
+		err.msg = known.msg
+	} else {
+		err.msg = fmt.Sprintf("unknown ASIO error %d", code)
+	}
+	return err
+}
 
-	switch errno {
-	case ASE_OK:
-		return nil
-	case ASE_SUCCESS:
-		return nil
+func (err *Error) Error() string {
+	s := err.msg
+	if err.Detail != "" && err.Detail != err.msg {
+		s += ": " + err.Detail
+	}
+	if err.Op != "" {
+		s = err.Op + ": " + s
 	}
-	if err, ok := knownErrors[errno]; ok {
-		return err
+	if err.Driver != "" {
+		s = err.Driver + ": " + s
 	}
+	return s
+}
 
-	// This rarely seems to return anything useful
-	return &Error{errno: errno, msg: drv.GetErrorMessage()}
+// Code returns the raw ASIOError code, such as ASE_NotPresent.
+func (err *Error) Code() int32 {
+	return err.errno
 }
 
-func (err *Error) Error() string {
-	return err.msg
+// Is reports whether target is an *Error with the same code.
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.errno == err.errno
+}
+
+// Unwrap returns the fixed instance of the code, if it is a known one.
+func (err *Error) Unwrap() error {
+	if known, ok := knownErrors[err.errno]; ok && known != err {
+		return known
+	}
+	return nil
+}
+
+// wrapError attributes an error returned by a driver call to the call and the driver.
+// An *Error is returned as a copy with Op and Driver filled in where the driver left them empty;
+// other errors are wrapped.
+func wrapError(op, driver string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		wrapped := *e
+		if wrapped.Op == "" {
+			wrapped.Op = op
+		}
+		if wrapped.Driver == "" {
+			wrapped.Driver = driver
+		}
+		return &wrapped
+	}
+	return fmt.Errorf("%s: %s: %w", driver, op, err)
 }
 
 type SampleType int32
@@ -166,22 +159,20 @@ const (
 	ASIOSTDSDInt8NER8 SampleType = 40 // DSD 8 bit data, 1 sample per byte. No Endianness required.
 )
 
-type rawChannelInfo struct {
-	Channel      int32
-	IsInput      int32
-	IsActive     int32
-	ChannelGroup int32
-	SampleType   SampleType
-	Name         [32]byte
-
-	// NOTE(jsd): for struct layout, `long` is `int32` regardless of `uintptr` size.
-
-	//	long channel;			// on input, channel index
-	//	ASIOBool isInput;		// on input
-	//	ASIOBool isActive;		// on exit
-	//	long channelGroup;		// dto
-	//	ASIOSampleType type;	// dto
-	//	char name[32];			// dto
+// Size returns the number of bytes one sample of this type occupies in a buffer.
+// For the packed DSD types a buffer sample is one byte holding 8 DSD samples.
+func (t SampleType) Size() int {
+	switch t {
+	case ASIOSTInt16MSB, ASIOSTInt16LSB:
+		return 2
+	case ASIOSTInt24MSB, ASIOSTInt24LSB:
+		return 3
+	case ASIOSTFloat64MSB, ASIOSTFloat64LSB:
+		return 8
+	case ASIOSTDSDInt8LSB1, ASIOSTDSDInt8MSB1, ASIOSTDSDInt8NER8:
+		return 1
+	}
+	return 4
 }
 
 type ChannelInfo struct {
@@ -189,65 +180,61 @@ type ChannelInfo struct {
 	IsInput      bool
 	IsActive     bool
 	ChannelGroup int
-	SampleType   int
+	SampleType   SampleType
 	Name         string
 }
 
-type rawBufferInfo struct {
-	isInput int32     // input
-	channel int32     // input
-	buffers [2]*int32 // output
-
-	//	ASIOBool isInput;			// on input:  ASIOTrue: input, else output
-	//	long channelNum;			// on input:  channel index
-	//	void *buffers[2];			// on output: double buffer addresses
+// ClockSource is a clock the driver can synchronize to, such as word clock or S/PDIF.
+type ClockSource struct {
+	Index             int // as passed to SetClockSource
+	AssociatedChannel int // first channel of the associated input, or -1
+	AssociatedGroup   int // channel group of the associated input, or -1
+	IsCurrentSource   bool
+	Name              string
 }
 
+// FutureSelector selects an extension of the driver, called through Driver.Future.
+type FutureSelector int32
+
 type BufferInfo struct {
 	Channel int
 	IsInput bool
 	Buffers [2]*int32 // double buffers - may need to recast based on sample type (int32 most popular; ASIOSTInt32LSB)
 }
 
-type rawASIOTime struct { // both input/output
-	//	long reserved[4];                       // must be 0
-	//	struct AsioTimeInfo     timeInfo;       // required
-	//	struct ASIOTimeCode     timeCode;       // optional, evaluated if (timeCode.flags & kTcValid)
+// ASIOTime mirrors the C ASIOTime struct passed to bufferSwitchTimeInfo.
+type ASIOTime struct { // both input/output
+	reserved [4]int32     // must be 0
+	timeInfo asioTimeInfo // required
+	timeCode asioTimeCode // optional, evaluated if (timeCode.flags & kTcValid)
 }
 
-type ASIOTime = C.ASIOTime
-type long = C.long
-
-var callback_funcs = Callbacks{}
+// NOTE: `long` is `int32` in ASIO structs regardless of `uintptr` size.
 
-//export onBufferSwitch
-func onBufferSwitch(doubleBufferIndex long, directProcess long) {
-	if callback_funcs.BufferSwitch != nil {
-		callback_funcs.BufferSwitch(int32(doubleBufferIndex), int32_bool(int32(directProcess)))
-	}
+type asioSamples struct {
+	hi uint32
+	lo uint32
 }
 
-//export onSampleRateDidChange
-func onSampleRateDidChange(rate float64) {
-	if callback_funcs.SampleRateDidChange != nil {
-		callback_funcs.SampleRateDidChange(rate)
-	}
+type asioTimeStamp struct {
+	hi uint32
+	lo uint32
 }
 
-//export onASIOMessage
-func onASIOMessage(selector long, value long, message unsafe.Pointer, opt *float64) long {
-	if callback_funcs.AsioMessage != nil {
-		return long(callback_funcs.AsioMessage(int32(selector), int32(value), uintptr(message), opt))
-	}
-	return 0
+type asioTimeInfo struct {
+	speed          float64       // absolute speed (1. = nominal)
+	systemTime     asioTimeStamp // system time related to samplePosition, in nanoseconds
+	samplePosition asioSamples
+	sampleRate     float64 // current rate
+	flags          uint32
+	reserved       [12]byte
 }
 
-//export onBufferSwitchTimeInfo
-func onBufferSwitchTimeInfo(params *ASIOTime, doubleBufferIndex long, directProcess long) *ASIOTime {
-	if callback_funcs.BufferSwitchTimeInfo != nil {
-		return callback_funcs.BufferSwitchTimeInfo(params, int32(doubleBufferIndex), int32_bool(int32(directProcess)))
-	}
-	return nil
+type asioTimeCode struct {
+	speed           float64     // speed relation (fraction of nominal speed)
+	timeCodeSamples asioSamples // time in samples
+	flags           uint32      // some information flags
+	future          [64]byte
 }
 
 type Callbacks struct {
@@ -260,221 +247,6 @@ type Callbacks struct {
 	BufferSwitchTimeInfo func(params *ASIOTime, doubleBufferIndex int32, directProcess bool) *ASIOTime
 }
 
-// interface IASIO : public IUnknown {
-type pIASIOVtbl struct {
-	// v-tables are flattened in memory for simple direct cases like this.
-	pIUnknownVtbl
-
-	//virtual ASIOBool init(void *sysHandle) = 0;
-	pInit uintptr
-	//virtual void getDriverName(char *name) = 0;
-	pGetDriverName uintptr
-	//virtual long getDriverVersion() = 0;
-	pGetDriverVersion uintptr
-	//virtual void getErrorMessage(char *string) = 0;
-	pGetErrorMessage uintptr
-
-	//virtual ASIOError start() = 0;
-	pStart uintptr
-	//virtual ASIOError stop() = 0;
-	pStop uintptr
-	//virtual ASIOError getChannels(long *numInputChannels, long *numOutputChannels) = 0;
-	pGetChannels uintptr
-	//virtual ASIOError getLatencies(long *inputLatency, long *outputLatency) = 0;
-	pGetLatencies uintptr
-	//virtual ASIOError getBufferSize(long *minSize, long *maxSize, long *preferredSize, long *granularity) = 0;
-	pGetBufferSize uintptr
-	//virtual ASIOError canSampleRate(ASIOSampleRate sampleRate) = 0;
-	pCanSampleRate uintptr
-	//virtual ASIOError getSampleRate(ASIOSampleRate *sampleRate) = 0;
-	pGetSampleRate uintptr
-	//virtual ASIOError setSampleRate(ASIOSampleRate sampleRate) = 0;
-	pSetSampleRate uintptr
-	//virtual ASIOError getClockSources(ASIOClockSource *clocks, long *numSources) = 0;
-	pGetClockSources uintptr
-	//virtual ASIOError setClockSource(long reference) = 0;
-	pSetClockSource uintptr
-	//virtual ASIOError getSamplePosition(ASIOSamples *sPos, ASIOTimeStamp *tStamp) = 0;
-	pGetSamplePosition uintptr
-	//virtual ASIOError getChannelInfo(ASIOChannelInfo *info) = 0;
-	pGetChannelInfo uintptr
-	//virtual ASIOError createBuffers(ASIOBufferInfo *bufferInfos, long numChannels, long bufferSize, ASIOCallbacks *callbacks) = 0;
-	pCreateBuffers uintptr
-	//virtual ASIOError disposeBuffers() = 0;
-	pDisposeBuffers uintptr
-	//virtual ASIOError controlPanel() = 0;
-	pControlPanel uintptr
-	//virtual ASIOError future(long selector,void *opt) = 0;
-	pFuture uintptr
-	//virtual ASIOError outputReady() = 0;
-	pOutputReady uintptr
-}
-
-// COM Interface for ASIO driver
-type IASIO struct {
-	vtbl_asio *pIASIOVtbl
-}
-
-// Cast to *IUnknown.
-func (drv *IASIO) AsIUnknown() *IUnknown { return (*IUnknown)(unsafe.Pointer(drv)) }
-
-// virtual ASIOBool init(void *sysHandle) = 0;
-func (drv *IASIO) Init(sysHandle uintptr) (ok bool) {
-	r1, _, _ := syscall.SyscallN(drv.vtbl_asio.pInit,
-		uintptr(unsafe.Pointer(drv)),
-		sysHandle)
-	ok = (r1 != 0)
-	return
-}
-
-// virtual void getDriverName(char *name) = 0;
-func (drv *IASIO) GetDriverName() string {
-	name := [128]byte{0}
-	syscall.SyscallN(drv.vtbl_asio.pGetDriverName,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&name[0])))
-
-	lz := bytes.IndexByte(name[:], byte(0))
-	return string(name[:lz])
-}
-
-// virtual long getDriverVersion() = 0;
-func (drv *IASIO) GetDriverVersion() int32 {
-	r1, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetDriverVersion,
-		uintptr(unsafe.Pointer(drv)))
-	return int32(r1)
-}
-
-// virtual void getErrorMessage(char *string) = 0;
-func (drv *IASIO) GetErrorMessage() string {
-	str := [128]byte{0}
-
-	_, _, _ = syscall.SyscallN(drv.vtbl_asio.pGetErrorMessage,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&str[0])))
-
-	lz := bytes.IndexByte(str[:], byte(0))
-	return string(str[:lz])
-}
-
-// virtual ASIOError start() = 0;
-func (drv *IASIO) Start() (err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pStart,
-		uintptr(unsafe.Pointer(drv)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-	return nil
-}
-
-// virtual ASIOError stop() = 0;
-func (drv *IASIO) Stop() (err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pStop,
-		uintptr(unsafe.Pointer(drv)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-	return nil
-}
-
-// virtual ASIOError getChannels(long *numInputChannels, long *numOutputChannels) = 0;
-func (drv *IASIO) GetChannels() (numInputChannels, numOutputChannels int, err error) {
-	var tmpInputChannels, tmpOutputChannels uintptr
-
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetChannels,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&tmpInputChannels)),
-		uintptr(unsafe.Pointer(&tmpOutputChannels)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return 0, 0, derr
-	}
-
-	return int(tmpInputChannels), int(tmpOutputChannels), nil
-}
-
-// virtual ASIOError getLatencies(long *inputLatency, long *outputLatency) = 0;
-func (drv *IASIO) GetLatencies() (inputLatency, outputLatency int, err error) {
-	var tmpInputLatency, tmpOutputLatency uintptr
-
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetLatencies,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&tmpInputLatency)),
-		uintptr(unsafe.Pointer(&tmpOutputLatency)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return 0, 0, derr
-	}
-
-	return int(tmpInputLatency), int(tmpOutputLatency), nil
-}
-
-// virtual ASIOError getBufferSize(long *minSize, long *maxSize, long *preferredSize, long *granularity) = 0;
-func (drv *IASIO) GetBufferSize() (minSize, maxSize, preferredSize, granularity int, err error) {
-	var tmpminSize, tmpmaxSize, tmppreferredSize, tmpgranularity uintptr
-
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetBufferSize,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&tmpminSize)),
-		uintptr(unsafe.Pointer(&tmpmaxSize)),
-		uintptr(unsafe.Pointer(&tmppreferredSize)),
-		uintptr(unsafe.Pointer(&tmpgranularity)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return 0, 0, 0, 0, derr
-	}
-
-	return int(tmpminSize), int(tmpmaxSize), int(tmppreferredSize), int(tmpgranularity), nil
-}
-
-// typedef double ASIOSampleRate;
-
-// virtual ASIOError canSampleRate(ASIOSampleRate sampleRate) = 0;
-func (drv *IASIO) CanSampleRate(sampleRate float64) (err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pCanSampleRate,
-		uintptr(unsafe.Pointer(drv)),
-		*(*uintptr)(unsafe.Pointer(&sampleRate)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-	return nil
-}
-
-// virtual ASIOError getSampleRate(ASIOSampleRate *sampleRate) = 0;
-func (drv *IASIO) GetSampleRate() (sampleRate float64, err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetSampleRate,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&sampleRate)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return 0., derr
-	}
-	return sampleRate, nil
-}
-
-// virtual ASIOError setSampleRate(ASIOSampleRate sampleRate) = 0;
-func (drv *IASIO) SetSampleRate(sampleRate float64) (err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pSetSampleRate,
-		uintptr(unsafe.Pointer(drv)),
-		*(*uintptr)(unsafe.Pointer(&sampleRate)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-	return nil
-}
-
-////virtual ASIOError getClockSources(ASIOClockSource *clocks, long *numSources) = 0;
-//pGetClockSources uintptr
-////virtual ASIOError setClockSource(long reference) = 0;
-//pSetClockSource uintptr
-
-////virtual ASIOError getSamplePosition(ASIOSamples *sPos, ASIOTimeStamp *tStamp) = 0;
-//pGetSamplePosition uintptr
-
 func bool_int32(a bool) int32 {
 	if a {
 		return 1
@@ -485,109 +257,3 @@ func bool_int32(a bool) int32 {
 func int32_bool(a int32) bool {
 	return a != 0
 }
-
-// virtual ASIOError getChannelInfo(ASIOChannelInfo *info) = 0;
-func (drv *IASIO) GetChannelInfo(channel int, isInput bool) (info *ChannelInfo, err error) {
-	raw := &rawChannelInfo{
-		Channel: int32(channel),
-		IsInput: bool_int32(isInput),
-	}
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetChannelInfo,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(raw)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return nil, derr
-	}
-
-	info = &ChannelInfo{
-		Channel:      int(raw.Channel),
-		IsInput:      int32_bool(raw.IsInput),
-		IsActive:     int32_bool(raw.IsActive),
-		ChannelGroup: int(raw.ChannelGroup),
-		SampleType:   int(raw.SampleType),
-		Name:         string(raw.Name[:]),
-	}
-	return info, nil
-}
-
-type callbacks struct {
-	pBufferSwitch         uintptr
-	pSampleRateDidChange  uintptr
-	pASIOMessage          uintptr
-	pBufferSwitchTimeInfo uintptr
-}
-
-// Create a GC root so this does not get collected.
-var the_callbacks = &callbacks{
-	pBufferSwitch:         uintptr(C.onBufferSwitch),
-	pSampleRateDidChange:  uintptr(C.onSampleRateDidChange),
-	pASIOMessage:          uintptr(C.onASIOMessage),
-	pBufferSwitchTimeInfo: uintptr(C.onBufferSwitchTimeInfo),
-}
-
-// virtual ASIOError createBuffers(ASIOBufferInfo *bufferInfos, long numChannels, long bufferSize, ASIOCallbacks *callbacks) = 0;
-func (drv *IASIO) CreateBuffers(bufferDescriptors []BufferInfo, bufferSize int, callbacks Callbacks) (err error) {
-	// Prepare the raw struct for holding ASIOBufferInfos:
-	rawBufferInfos := make([]rawBufferInfo, len(bufferDescriptors))
-	for i, desc := range bufferDescriptors {
-		rawBufferInfos[i].channel = int32(desc.Channel)
-		rawBufferInfos[i].isInput = bool_int32(desc.IsInput)
-		rawBufferInfos[i].buffers = [2]*int32{nil, nil}
-	}
-
-	// Set global callbacks.
-	// NOTE: ASIO callbacks do not supply a context argument and so cannot generally be made driver-specific.
-	callback_funcs = callbacks
-
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pCreateBuffers,
-		uintptr(unsafe.Pointer(drv)),
-		uintptr(unsafe.Pointer(&rawBufferInfos[0])),
-		uintptr(len(bufferDescriptors)),
-		uintptr(bufferSize),
-		uintptr(unsafe.Pointer(the_callbacks)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-
-	// Project output buffer addresses back into input `[]BufferInfo`:
-	for i := range bufferDescriptors {
-		bufferDescriptors[i].Buffers = rawBufferInfos[i].buffers
-	}
-
-	return nil
-}
-
-// virtual ASIOError disposeBuffers() = 0;
-func (drv *IASIO) DisposeBuffers() (err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pDisposeBuffers,
-		uintptr(unsafe.Pointer(drv)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-	return nil
-}
-
-// virtual ASIOError controlPanel() = 0;
-func (drv *IASIO) ControlPanel() (err error) {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pControlPanel,
-		uintptr(unsafe.Pointer(drv)))
-
-	if derr := drv.asError(ase); derr != nil {
-		return derr
-	}
-	return nil
-}
-
-////virtual ASIOError future(long selector,void *opt) = 0;
-//pFuture uintptr
-
-// virtual ASIOError outputReady() = 0;
-func (drv *IASIO) OutputReady() bool {
-	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pOutputReady,
-		uintptr(unsafe.Pointer(drv)))
-
-	return int32(ase) == int32(ASE_OK)
-}
@@ -0,0 +1,107 @@
+package asio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	err := newError(ASE_HWMalfunction, "Start", "Card", "fan stopped")
+	if !errors.Is(err, ErrorHWMalfunction) || errors.Is(err, ErrorNotPresent) {
+		t.Errorf("%v matches the wrong fixed errors", err)
+	}
+	if errors.Unwrap(err) != ErrorHWMalfunction {
+		t.Errorf("Unwrap() = %v", errors.Unwrap(err))
+	}
+	if err.Code() != ASE_HWMalfunction {
+		t.Errorf("Code() = %d", err.Code())
+	}
+	if want := "Card: Start: " + ErrorHWMalfunction.msg + ": fan stopped"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	unknown := newError(-42, "Stop", "Card", "")
+	for _, known := range knownErrors {
+		if errors.Is(unknown, known) {
+			t.Errorf("unknown code matches %v", known)
+		}
+	}
+	if unknown.Code() != -42 || !strings.Contains(unknown.Error(), "-42") {
+		t.Errorf("unknown code: %d, %q", unknown.Code(), unknown)
+	}
+	if !errors.Is(unknown, newError(-42, "", "", "")) {
+		t.Error("unknown code does not match itself")
+	}
+
+	wrapped := wrapError("Start", "Card", ErrorNoClock)
+	var e *Error
+	if !errors.As(wrapped, &e) || e.Op != "Start" || e.Driver != "Card" || !errors.Is(wrapped, ErrorNoClock) {
+		t.Errorf("wrapped fixed error: %#v", e)
+	}
+	if ErrorNoClock.Op != "" || ErrorNoClock.Driver != "" {
+		t.Error("wrapping modified the fixed error")
+	}
+	if e := wrapError("Stop", "Other", err).(*Error); e.Op != "Start" || e.Driver != "Card" {
+		t.Errorf("wrapping replaced the context of %v: %v", err, e)
+	}
+	if wrapError("Start", "Card", nil) != nil {
+		t.Error("wrapped nil")
+	}
+}
+
+// failingDriver fails Init and Start like a driver whose hardware is gone.
+type failingDriver struct {
+	*SimDriver
+	init bool
+}
+
+func (drv failingDriver) Init(sysHandle uintptr) bool {
+	return drv.init && drv.SimDriver.Init(sysHandle)
+}
+
+func (drv failingDriver) GetErrorMessage() string {
+	return "hardware unplugged"
+}
+
+func (drv failingDriver) Start() error {
+	return ErrorHWMalfunction
+}
+
+func TestDeviceErrors(t *testing.T) {
+	sim := NewSimDriver(SimConfig{Name: "Card"})
+
+	device := Device{}
+	err := device.LoadDriver(failingDriver{SimDriver: sim})
+	if !errors.Is(err, ErrInitFailed) || !strings.Contains(err.Error(), "hardware unplugged") {
+		t.Errorf("LoadDriver of a failing driver: %v", err)
+	}
+
+	if err := device.LoadDriver(failingDriver{SimDriver: sim, init: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	err = device.SetSampleRate(12345)
+	var e *Error
+	if !errors.As(err, &e) || e.Op != "SetSampleRate" || e.Driver != "Card" || e.Code() != ASE_NoClock || e.Detail == "" {
+		t.Errorf("SetSampleRate: %#v", err)
+	}
+
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	err = device.Start(func(in, out [][]int32) {})
+	if !errors.As(err, &e) || e.Op != "Start" || e.Driver != "Card" || !errors.Is(err, ErrorHWMalfunction) {
+		t.Errorf("Start: %v", err)
+	}
+
+	err = Session{
+		Driver:    failingDriver{SimDriver: NewSimDriver(SimConfig{Name: "Card"}), init: true},
+		IOHandler: func(in, out [][]int32) {},
+		WaitFunc:  func() {},
+	}.Run()
+	if !errors.Is(err, ErrorHWMalfunction) || !strings.HasPrefix(err.Error(), "session: Card: Start: ") {
+		t.Errorf("Session.Run: %v", err)
+	}
+}
@@ -0,0 +1,507 @@
+//go:build windows
+
+package asio
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+)
+
+/*
+#include <string.h>
+#include "asio_windows.h"
+*/
+import "C"
+
+// asError converts the result of a driver call named op.
+func (drv *IASIO) asError(op string, ase uintptr) *Error {
+	errno := int32(ase)
+
+	switch errno {
+	case ASE_OK:
+		return nil
+	case ASE_SUCCESS:
+		return nil
+	}
+
+	// The driver's message rarely says anything useful, but it is the only description of unknown codes
+	return newError(errno, op, drv.GetDriverName(), drv.GetErrorMessage())
+}
+
+type rawChannelInfo struct {
+	Channel      int32
+	IsInput      int32
+	IsActive     int32
+	ChannelGroup int32
+	SampleType   SampleType
+	Name         [32]byte
+
+	// NOTE(jsd): for struct layout, `long` is `int32` regardless of `uintptr` size.
+
+	//	long channel;			// on input, channel index
+	//	ASIOBool isInput;		// on input
+	//	ASIOBool isActive;		// on exit
+	//	long channelGroup;		// dto
+	//	ASIOSampleType type;	// dto
+	//	char name[32];			// dto
+}
+
+type rawBufferInfo struct {
+	isInput int32     // input
+	channel int32     // input
+	buffers [2]*int32 // output
+
+	//	ASIOBool isInput;			// on input:  ASIOTrue: input, else output
+	//	long channelNum;			// on input:  channel index
+	//	void *buffers[2];			// on output: double buffer addresses
+}
+
+type long = C.long
+
+// Called by the trampolines of callbacks_windows.c with their slot number.
+
+//export goBufferSwitch
+func goBufferSwitch(slot C.int, doubleBufferIndex long, directProcess long) {
+	if f := slotCallbacks(int(slot)).BufferSwitch; f != nil {
+		f(int32(doubleBufferIndex), int32_bool(int32(directProcess)))
+	}
+}
+
+//export goSampleRateDidChange
+func goSampleRateDidChange(slot C.int, rate float64) {
+	if f := slotCallbacks(int(slot)).SampleRateDidChange; f != nil {
+		f(rate)
+	}
+}
+
+//export goAsioMessage
+func goAsioMessage(slot C.int, selector long, value long, message unsafe.Pointer, opt *float64) long {
+	if f := slotCallbacks(int(slot)).AsioMessage; f != nil {
+		return long(f(int32(selector), int32(value), uintptr(message), opt))
+	}
+	return 0
+}
+
+//export goBufferSwitchTimeInfo
+func goBufferSwitchTimeInfo(slot C.int, params *C.ASIOTime, doubleBufferIndex long, directProcess long) *C.ASIOTime {
+	if f := slotCallbacks(int(slot)).BufferSwitchTimeInfo; f != nil {
+		return (*C.ASIOTime)(unsafe.Pointer(f(
+			(*ASIOTime)(unsafe.Pointer(params)), int32(doubleBufferIndex), int32_bool(int32(directProcess)))))
+	}
+	return nil
+}
+
+// interface IASIO : public IUnknown {
+type pIASIOVtbl struct {
+	// v-tables are flattened in memory for simple direct cases like this.
+	pIUnknownVtbl
+
+	//virtual ASIOBool init(void *sysHandle) = 0;
+	pInit uintptr
+	//virtual void getDriverName(char *name) = 0;
+	pGetDriverName uintptr
+	//virtual long getDriverVersion() = 0;
+	pGetDriverVersion uintptr
+	//virtual void getErrorMessage(char *string) = 0;
+	pGetErrorMessage uintptr
+
+	//virtual ASIOError start() = 0;
+	pStart uintptr
+	//virtual ASIOError stop() = 0;
+	pStop uintptr
+	//virtual ASIOError getChannels(long *numInputChannels, long *numOutputChannels) = 0;
+	pGetChannels uintptr
+	//virtual ASIOError getLatencies(long *inputLatency, long *outputLatency) = 0;
+	pGetLatencies uintptr
+	//virtual ASIOError getBufferSize(long *minSize, long *maxSize, long *preferredSize, long *granularity) = 0;
+	pGetBufferSize uintptr
+	//virtual ASIOError canSampleRate(ASIOSampleRate sampleRate) = 0;
+	pCanSampleRate uintptr
+	//virtual ASIOError getSampleRate(ASIOSampleRate *sampleRate) = 0;
+	pGetSampleRate uintptr
+	//virtual ASIOError setSampleRate(ASIOSampleRate sampleRate) = 0;
+	pSetSampleRate uintptr
+	//virtual ASIOError getClockSources(ASIOClockSource *clocks, long *numSources) = 0;
+	pGetClockSources uintptr
+	//virtual ASIOError setClockSource(long reference) = 0;
+	pSetClockSource uintptr
+	//virtual ASIOError getSamplePosition(ASIOSamples *sPos, ASIOTimeStamp *tStamp) = 0;
+	pGetSamplePosition uintptr
+	//virtual ASIOError getChannelInfo(ASIOChannelInfo *info) = 0;
+	pGetChannelInfo uintptr
+	//virtual ASIOError createBuffers(ASIOBufferInfo *bufferInfos, long numChannels, long bufferSize, ASIOCallbacks *callbacks) = 0;
+	pCreateBuffers uintptr
+	//virtual ASIOError disposeBuffers() = 0;
+	pDisposeBuffers uintptr
+	//virtual ASIOError controlPanel() = 0;
+	pControlPanel uintptr
+	//virtual ASIOError future(long selector,void *opt) = 0;
+	pFuture uintptr
+	//virtual ASIOError outputReady() = 0;
+	pOutputReady uintptr
+}
+
+// COM Interface for ASIO driver
+type IASIO struct {
+	vtbl_asio *pIASIOVtbl
+}
+
+var (
+	_ Driver      = (*IASIO)(nil)
+	_ futureCoder = (*IASIO)(nil)
+)
+
+// Cast to *IUnknown.
+func (drv *IASIO) AsIUnknown() *IUnknown { return (*IUnknown)(unsafe.Pointer(drv)) }
+
+// virtual ASIOBool init(void *sysHandle) = 0;
+func (drv *IASIO) Init(sysHandle uintptr) (ok bool) {
+	r1, _, _ := syscall.SyscallN(drv.vtbl_asio.pInit,
+		uintptr(unsafe.Pointer(drv)),
+		sysHandle)
+	ok = (r1 != 0)
+	return
+}
+
+// virtual void getDriverName(char *name) = 0;
+func (drv *IASIO) GetDriverName() string {
+	name := [128]byte{0}
+	syscall.SyscallN(drv.vtbl_asio.pGetDriverName,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&name[0])))
+
+	lz := bytes.IndexByte(name[:], byte(0))
+	return string(name[:lz])
+}
+
+// virtual long getDriverVersion() = 0;
+func (drv *IASIO) GetDriverVersion() int32 {
+	r1, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetDriverVersion,
+		uintptr(unsafe.Pointer(drv)))
+	return int32(r1)
+}
+
+// virtual void getErrorMessage(char *string) = 0;
+func (drv *IASIO) GetErrorMessage() string {
+	str := [128]byte{0}
+
+	_, _, _ = syscall.SyscallN(drv.vtbl_asio.pGetErrorMessage,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&str[0])))
+
+	lz := bytes.IndexByte(str[:], byte(0))
+	return string(str[:lz])
+}
+
+// virtual ASIOError start() = 0;
+func (drv *IASIO) Start() (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pStart,
+		uintptr(unsafe.Pointer(drv)))
+
+	if derr := drv.asError("Start", ase); derr != nil {
+		return derr
+	}
+	return nil
+}
+
+// virtual ASIOError stop() = 0;
+func (drv *IASIO) Stop() (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pStop,
+		uintptr(unsafe.Pointer(drv)))
+
+	if derr := drv.asError("Stop", ase); derr != nil {
+		return derr
+	}
+	return nil
+}
+
+// virtual ASIOError getChannels(long *numInputChannels, long *numOutputChannels) = 0;
+func (drv *IASIO) GetChannels() (numInputChannels, numOutputChannels int, err error) {
+	var tmpInputChannels, tmpOutputChannels uintptr
+
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetChannels,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&tmpInputChannels)),
+		uintptr(unsafe.Pointer(&tmpOutputChannels)))
+
+	if derr := drv.asError("GetChannels", ase); derr != nil {
+		return 0, 0, derr
+	}
+
+	return int(tmpInputChannels), int(tmpOutputChannels), nil
+}
+
+// virtual ASIOError getLatencies(long *inputLatency, long *outputLatency) = 0;
+func (drv *IASIO) GetLatencies() (inputLatency, outputLatency int, err error) {
+	var tmpInputLatency, tmpOutputLatency uintptr
+
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetLatencies,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&tmpInputLatency)),
+		uintptr(unsafe.Pointer(&tmpOutputLatency)))
+
+	if derr := drv.asError("GetLatencies", ase); derr != nil {
+		return 0, 0, derr
+	}
+
+	return int(tmpInputLatency), int(tmpOutputLatency), nil
+}
+
+// virtual ASIOError getBufferSize(long *minSize, long *maxSize, long *preferredSize, long *granularity) = 0;
+func (drv *IASIO) GetBufferSize() (minSize, maxSize, preferredSize, granularity int, err error) {
+	var tmpminSize, tmpmaxSize, tmppreferredSize, tmpgranularity uintptr
+
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetBufferSize,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&tmpminSize)),
+		uintptr(unsafe.Pointer(&tmpmaxSize)),
+		uintptr(unsafe.Pointer(&tmppreferredSize)),
+		uintptr(unsafe.Pointer(&tmpgranularity)))
+
+	if derr := drv.asError("GetBufferSize", ase); derr != nil {
+		return 0, 0, 0, 0, derr
+	}
+
+	return int(tmpminSize), int(tmpmaxSize), int(tmppreferredSize), int(tmpgranularity), nil
+}
+
+// typedef double ASIOSampleRate;
+
+// virtual ASIOError canSampleRate(ASIOSampleRate sampleRate) = 0;
+func (drv *IASIO) CanSampleRate(sampleRate float64) (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pCanSampleRate,
+		uintptr(unsafe.Pointer(drv)),
+		*(*uintptr)(unsafe.Pointer(&sampleRate)))
+
+	if derr := drv.asError("CanSampleRate", ase); derr != nil {
+		return derr
+	}
+	return nil
+}
+
+// virtual ASIOError getSampleRate(ASIOSampleRate *sampleRate) = 0;
+func (drv *IASIO) GetSampleRate() (sampleRate float64, err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetSampleRate,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&sampleRate)))
+
+	if derr := drv.asError("GetSampleRate", ase); derr != nil {
+		return 0., derr
+	}
+	return sampleRate, nil
+}
+
+// virtual ASIOError setSampleRate(ASIOSampleRate sampleRate) = 0;
+func (drv *IASIO) SetSampleRate(sampleRate float64) (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pSetSampleRate,
+		uintptr(unsafe.Pointer(drv)),
+		*(*uintptr)(unsafe.Pointer(&sampleRate)))
+
+	if derr := drv.asError("SetSampleRate", ase); derr != nil {
+		return derr
+	}
+	return nil
+}
+
+type rawClockSource struct {
+	Index             int32
+	AssociatedChannel int32
+	AssociatedGroup   int32
+	IsCurrentSource   int32
+	Name              [32]byte
+
+	//	long index;					// as used for ASIOSetClockSource()
+	//	long associatedChannel;		// for instance, S/PDIF or AES/EBU
+	//	long associatedGroup;		// see channel groups (ASIOGetChannelInfo())
+	//	ASIOBool isCurrentSource;	// ASIOTrue if this is the current clock source
+	//	char name[32];				// for user selection
+}
+
+// virtual ASIOError getClockSources(ASIOClockSource *clocks, long *numSources) = 0;
+func (drv *IASIO) GetClockSources() (sources []ClockSource, err error) {
+	raw := make([]rawClockSource, 32)
+	query := func() (int, *Error) {
+		// on input: number of allocated array members, on output: number of available clock sources
+		numSources := uintptr(len(raw))
+		ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetClockSources,
+			uintptr(unsafe.Pointer(drv)),
+			uintptr(unsafe.Pointer(&raw[0])),
+			uintptr(unsafe.Pointer(&numSources)))
+		return int(int32(numSources)), drv.asError("GetClockSources", ase)
+	}
+
+	n, derr := query()
+	if derr == nil && n > len(raw) {
+		raw = make([]rawClockSource, n)
+		n, derr = query()
+	}
+	if derr != nil {
+		return nil, derr
+	}
+	raw = raw[:max(0, min(n, len(raw)))]
+
+	sources = make([]ClockSource, len(raw))
+	for i, r := range raw {
+		sources[i] = ClockSource{
+			Index:             int(r.Index),
+			AssociatedChannel: int(r.AssociatedChannel),
+			AssociatedGroup:   int(r.AssociatedGroup),
+			IsCurrentSource:   int32_bool(r.IsCurrentSource),
+			Name:              cString(r.Name[:]),
+		}
+	}
+	return sources, nil
+}
+
+// virtual ASIOError setClockSource(long reference) = 0;
+func (drv *IASIO) SetClockSource(index int) (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pSetClockSource,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(index))
+
+	if derr := drv.asError("SetClockSource", ase); derr != nil {
+		return derr
+	}
+	return nil
+}
+
+// virtual ASIOError getSamplePosition(ASIOSamples *sPos, ASIOTimeStamp *tStamp) = 0;
+func (drv *IASIO) GetSamplePosition() (samplePosition, timeStamp int64, err error) {
+	var sPos asioSamples
+	var tStamp asioTimeStamp
+
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetSamplePosition,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&sPos)),
+		uintptr(unsafe.Pointer(&tStamp)))
+
+	if derr := drv.asError("GetSamplePosition", ase); derr != nil {
+		return 0, 0, derr
+	}
+	return sPos.int64(), tStamp.int64(), nil
+}
+
+// virtual ASIOError getChannelInfo(ASIOChannelInfo *info) = 0;
+func (drv *IASIO) GetChannelInfo(channel int, isInput bool) (info *ChannelInfo, err error) {
+	raw := &rawChannelInfo{
+		Channel: int32(channel),
+		IsInput: bool_int32(isInput),
+	}
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pGetChannelInfo,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(raw)))
+
+	if derr := drv.asError("GetChannelInfo", ase); derr != nil {
+		return nil, derr
+	}
+
+	info = &ChannelInfo{
+		Channel:      int(raw.Channel),
+		IsInput:      int32_bool(raw.IsInput),
+		IsActive:     int32_bool(raw.IsActive),
+		ChannelGroup: int(raw.ChannelGroup),
+		SampleType:   raw.SampleType,
+		Name:         cString(raw.Name[:]),
+	}
+	return info, nil
+}
+
+// cString converts a NUL terminated string of a fixed size array.
+func cString(b []byte) string {
+	if lz := bytes.IndexByte(b, byte(0)); lz >= 0 {
+		b = b[:lz]
+	}
+	return string(b)
+}
+
+// The trampolines are generated for a fixed number of slots.
+var _ [C.ASIO_CALLBACK_SLOTS - callbackSlotCount]struct{}
+var _ [callbackSlotCount - C.ASIO_CALLBACK_SLOTS]struct{}
+
+// virtual ASIOError createBuffers(ASIOBufferInfo *bufferInfos, long numChannels, long bufferSize, ASIOCallbacks *callbacks) = 0;
+func (drv *IASIO) CreateBuffers(bufferDescriptors []BufferInfo, bufferSize int, callbacks Callbacks) (err error) {
+	// Prepare the raw struct for holding ASIOBufferInfos:
+	rawBufferInfos := make([]rawBufferInfo, len(bufferDescriptors))
+	for i, desc := range bufferDescriptors {
+		rawBufferInfos[i].channel = int32(desc.Channel)
+		rawBufferInfos[i].isInput = bool_int32(desc.IsInput)
+		rawBufferInfos[i].buffers = [2]*int32{nil, nil}
+	}
+
+	// NOTE: ASIO callbacks do not supply a context argument, so each driver
+	// gets the trampolines of its own callback slot.
+	slot, err := acquireCallbackSlot(drv, callbacks)
+	if err != nil {
+		return err
+	}
+
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pCreateBuffers,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(unsafe.Pointer(&rawBufferInfos[0])),
+		uintptr(len(bufferDescriptors)),
+		uintptr(bufferSize),
+		uintptr(unsafe.Pointer(C.slotTrampolines(C.int(slot)))))
+
+	if derr := drv.asError("CreateBuffers", ase); derr != nil {
+		releaseCallbackSlot(drv)
+		return derr
+	}
+
+	// Project output buffer addresses back into input `[]BufferInfo`:
+	for i := range bufferDescriptors {
+		bufferDescriptors[i].Buffers = rawBufferInfos[i].buffers
+	}
+
+	return nil
+}
+
+// virtual ASIOError disposeBuffers() = 0;
+func (drv *IASIO) DisposeBuffers() (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pDisposeBuffers,
+		uintptr(unsafe.Pointer(drv)))
+
+	if derr := drv.asError("DisposeBuffers", ase); derr != nil {
+		return derr
+	}
+	releaseCallbackSlot(drv)
+	return nil
+}
+
+// virtual ASIOError controlPanel() = 0;
+func (drv *IASIO) ControlPanel() (err error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pControlPanel,
+		uintptr(unsafe.Pointer(drv)))
+
+	if derr := drv.asError("ControlPanel", ase); derr != nil {
+		return derr
+	}
+	return nil
+}
+
+// virtual ASIOError future(long selector,void *opt) = 0;
+func (drv *IASIO) Future(selector FutureSelector, opt unsafe.Pointer) (err error) {
+	_, err = drv.futureCode(selector, opt)
+	return err
+}
+
+// futureCode is Future returning the result code, which tells ASE_SUCCESS from ASE_OK.
+func (drv *IASIO) futureCode(selector FutureSelector, opt unsafe.Pointer) (int32, error) {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pFuture,
+		uintptr(unsafe.Pointer(drv)),
+		uintptr(selector),
+		uintptr(opt))
+
+	if derr := drv.asError("Future", ase); derr != nil {
+		return int32(ase), derr
+	}
+	return int32(ase), nil
+}
+
+// virtual ASIOError outputReady() = 0;
+func (drv *IASIO) OutputReady() bool {
+	ase, _, _ := syscall.SyscallN(drv.vtbl_asio.pOutputReady,
+		uintptr(unsafe.Pointer(drv)))
+
+	return int32(ase) == int32(ASE_OK)
+}
@@ -0,0 +1,67 @@
+// Declarations shared by the cgo preamble of asio_windows.go and callbacks_windows.c.
+
+#ifndef GO_ASIO_H
+#define GO_ASIO_H
+
+typedef long ASIOBool;
+typedef double ASIOSampleRate;
+
+typedef struct ASIOSamples {
+	unsigned long hi;
+	unsigned long lo;
+} ASIOSamples;
+
+typedef struct ASIOTimeStamp {
+	unsigned long hi;
+	unsigned long lo;
+} ASIOTimeStamp;
+
+typedef struct ASIOTimeCode
+{
+	double          speed;                  // speed relation (fraction of nominal speed)
+	                                        // optional; set to 0. or 1. if not supported
+	ASIOSamples     timeCodeSamples;        // time in samples
+	unsigned long   flags;                  // some information flags (see below)
+	char future[64];
+} ASIOTimeCode;
+
+typedef struct AsioTimeInfo
+{
+	double          speed;                  // absolute speed (1. = nominal)
+	ASIOTimeStamp   systemTime;             // system time related to samplePosition, in nanoseconds
+	                                        // on mac, must be derived from Microseconds() (not UpTime()!)
+	                                        // on windows, must be derived from timeGetTime()
+	ASIOSamples     samplePosition;
+	ASIOSampleRate  sampleRate;             // current rate
+	unsigned long flags;                    // (see below)
+	char reserved[12];
+} AsioTimeInfo;
+
+typedef struct ASIOTime                          // both input/output
+{
+	long reserved[4];                       // must be 0
+	struct AsioTimeInfo     timeInfo;       // required
+	struct ASIOTimeCode     timeCode;       // optional, evaluated if (timeCode.flags & kTcValid)
+} ASIOTime;
+
+// Callback function pointer typedefs:
+typedef void (*bufferSwitch) (long doubleBufferIndex, ASIOBool directProcess);
+typedef void (*sampleRateDidChange) (ASIOSampleRate sRate);
+typedef long (*asioMessage) (long selector, long value, void* message, double* opt);
+typedef ASIOTime* (*bufferSwitchTimeInfo) (ASIOTime* params, long doubleBufferIndex, ASIOBool directProcess);
+
+typedef struct ASIOCallbacks
+{
+	bufferSwitch bufferSwitch;
+	sampleRateDidChange sampleRateDidChange;
+	asioMessage asioMessage;
+	bufferSwitchTimeInfo bufferSwitchTimeInfo;
+} ASIOCallbacks;
+
+// Number of trampoline sets, see callbackSlotCount.
+#define ASIO_CALLBACK_SLOTS 8
+
+// Trampolines forwarding to the Go callbacks of a slot.
+ASIOCallbacks* slotTrampolines(int slot);
+
+#endif
@@ -0,0 +1,71 @@
+package asio
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// callbackSlotCount is the number of drivers that can have buffers at the same time.
+//
+// ASIO callbacks carry no context argument, so each driver is handed its own
+// set of trampolines, which forward to the Callbacks of one slot. It must match
+// ASIO_CALLBACK_SLOTS in asio_windows.h.
+const callbackSlotCount = 8
+
+var (
+	callbackSlotsMu sync.Mutex
+	callbackOwners  [callbackSlotCount]any
+	callbackSlots   [callbackSlotCount]atomic.Pointer[Callbacks]
+)
+
+// acquireCallbackSlot binds callbacks to a free slot for owner, which is
+// usually the driver creating buffers. An owner that already holds a slot
+// keeps it, with its callbacks replaced.
+func acquireCallbackSlot(owner any, callbacks Callbacks) (int, error) {
+	callbackSlotsMu.Lock()
+	defer callbackSlotsMu.Unlock()
+
+	free := -1
+	for i, o := range callbackOwners {
+		if o == owner {
+			free = i
+			break
+		}
+		if o == nil && free < 0 {
+			free = i
+		}
+	}
+	if free < 0 {
+		return -1, fmt.Errorf("all %d callback slots in use: %w", callbackSlotCount, ErrorNoMemory)
+	}
+
+	callbackOwners[free] = owner
+	callbackSlots[free].Store(&callbacks)
+	return free, nil
+}
+
+// releaseCallbackSlot frees the slot held by owner, if any.
+func releaseCallbackSlot(owner any) {
+	callbackSlotsMu.Lock()
+	defer callbackSlotsMu.Unlock()
+
+	for i, o := range callbackOwners {
+		if o == owner {
+			callbackOwners[i] = nil
+			callbackSlots[i].Store(nil)
+		}
+	}
+}
+
+// slotCallbacks returns the callbacks bound to slot; they are all nil for a free slot.
+// It is called from the driver's threads and does not block.
+func slotCallbacks(slot int) Callbacks {
+	if slot < 0 || slot >= callbackSlotCount {
+		return Callbacks{}
+	}
+	if callbacks := callbackSlots[slot].Load(); callbacks != nil {
+		return *callbacks
+	}
+	return Callbacks{}
+}
@@ -0,0 +1,99 @@
+package asio
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestTwoDevices(t *testing.T) {
+	const steps = 50
+
+	var devices [2]Device
+	var sims [2]*SimDriver
+	var calls [2]int
+	for i := range devices {
+		sims[i] = NewSimDriver(SimConfig{InputChannels: i + 1, OutputChannels: 1, PreferredSize: 64})
+		if err := devices[i].LoadDriver(sims[i]); err != nil {
+			t.Fatal(err)
+		}
+		defer devices[i].Unload()
+		if err := devices[i].Open(); err != nil {
+			t.Fatal(err)
+		}
+		if err := devices[i].Start(func(in, out [][]int32) {
+			if len(in) != i+1 {
+				t.Errorf("device %d handler got %d inputs, want %d", i, len(in), i+1)
+			}
+			calls[i]++
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, sim := range sims {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sim.Step(steps)
+		}()
+	}
+	wg.Wait()
+
+	if calls != [2]int{steps, steps} {
+		t.Errorf("handlers called %v times, want %d each", calls, steps)
+	}
+
+	// messages reach the device of the driver sending them
+	events, cancel := devices[1].Subscribe()
+	defer cancel()
+	sims[0].SendMessage(kAsioOverload, 0)
+	if len(events) != 0 {
+		t.Error("message of the first driver delivered to the second device")
+	}
+	sims[1].SendMessage(kAsioOverload, 0)
+	if len(events) != 1 {
+		t.Error("message of the second driver not delivered")
+	}
+}
+
+func TestCallbackSlotsExhausted(t *testing.T) {
+	// fill the slots left free by other tests
+	var owners []*int
+	slots := map[*int]int{}
+	defer func() {
+		for _, owner := range owners {
+			releaseCallbackSlot(owner)
+		}
+	}()
+	for range callbackSlotCount {
+		owner := new(int)
+		slot, err := acquireCallbackSlot(owner, Callbacks{})
+		if err != nil {
+			break
+		}
+		owners = append(owners, owner)
+		slots[owner] = slot
+	}
+	if len(owners) == 0 {
+		t.Fatal("no free callback slot")
+	}
+
+	sim := NewSimDriver(SimConfig{})
+	sim.Init(0)
+	err := sim.CreateBuffers([]BufferInfo{{Channel: 0, IsInput: true}}, 256, Callbacks{})
+	if !errors.Is(err, ErrorNoMemory) {
+		t.Errorf("CreateBuffers with every slot in use: %v", err)
+	}
+
+	freed := owners[len(owners)-1]
+	releaseCallbackSlot(freed)
+	if err := sim.CreateBuffers([]BufferInfo{{Channel: 0, IsInput: true}}, 256, Callbacks{}); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.DisposeBuffers()
+	if slot, _ := acquireCallbackSlot(sim, Callbacks{}); slot != slots[freed] {
+		t.Errorf("sim got slot %d, want the freed slot %d", slot, slots[freed])
+	}
+}
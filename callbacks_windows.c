@@ -0,0 +1,49 @@
+// ASIO callbacks carry no context argument, so every callback slot gets its own
+// set of trampolines, which pass the slot number on to Go.
+
+#include "_cgo_export.h"
+
+#define SLOT(n)                                                                                     \
+	static void bufferSwitch##n(long doubleBufferIndex, ASIOBool directProcess)                     \
+	{                                                                                               \
+		goBufferSwitch(n, doubleBufferIndex, directProcess);                                        \
+	}                                                                                               \
+	static void sampleRateDidChange##n(ASIOSampleRate sRate)                                        \
+	{                                                                                               \
+		goSampleRateDidChange(n, sRate);                                                            \
+	}                                                                                               \
+	static long asioMessage##n(long selector, long value, void* message, double* opt)               \
+	{                                                                                               \
+		return goAsioMessage(n, selector, value, message, opt);                                     \
+	}                                                                                               \
+	static ASIOTime* bufferSwitchTimeInfo##n(ASIOTime* params, long doubleBufferIndex, ASIOBool directProcess) \
+	{                                                                                               \
+		return goBufferSwitchTimeInfo(n, params, doubleBufferIndex, directProcess);                 \
+	}
+
+SLOT(0)
+SLOT(1)
+SLOT(2)
+SLOT(3)
+SLOT(4)
+SLOT(5)
+SLOT(6)
+SLOT(7)
+
+#define TRAMPOLINES(n) { bufferSwitch##n, sampleRateDidChange##n, asioMessage##n, bufferSwitchTimeInfo##n }
+
+static ASIOCallbacks trampolines[ASIO_CALLBACK_SLOTS] = {
+	TRAMPOLINES(0),
+	TRAMPOLINES(1),
+	TRAMPOLINES(2),
+	TRAMPOLINES(3),
+	TRAMPOLINES(4),
+	TRAMPOLINES(5),
+	TRAMPOLINES(6),
+	TRAMPOLINES(7),
+};
+
+ASIOCallbacks* slotTrampolines(int slot)
+{
+	return &trampolines[slot];
+}
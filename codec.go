@@ -0,0 +1,281 @@
+package asio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+var sampleTypeNames = map[SampleType]string{
+	ASIOSTInt16MSB:    "Int16MSB",
+	ASIOSTInt24MSB:    "Int24MSB",
+	ASIOSTInt32MSB:    "Int32MSB",
+	ASIOSTFloat32MSB:  "Float32MSB",
+	ASIOSTFloat64MSB:  "Float64MSB",
+	ASIOSTInt32MSB16:  "Int32MSB16",
+	ASIOSTInt32MSB18:  "Int32MSB18",
+	ASIOSTInt32MSB20:  "Int32MSB20",
+	ASIOSTInt32MSB24:  "Int32MSB24",
+	ASIOSTInt16LSB:    "Int16LSB",
+	ASIOSTInt24LSB:    "Int24LSB",
+	ASIOSTInt32LSB:    "Int32LSB",
+	ASIOSTFloat32LSB:  "Float32LSB",
+	ASIOSTFloat64LSB:  "Float64LSB",
+	ASIOSTInt32LSB16:  "Int32LSB16",
+	ASIOSTInt32LSB18:  "Int32LSB18",
+	ASIOSTInt32LSB20:  "Int32LSB20",
+	ASIOSTInt32LSB24:  "Int32LSB24",
+	ASIOSTDSDInt8LSB1: "DSDInt8LSB1",
+	ASIOSTDSDInt8MSB1: "DSDInt8MSB1",
+	ASIOSTDSDInt8NER8: "DSDInt8NER8",
+}
+
+func (t SampleType) String() string {
+	if name, ok := sampleTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("SampleType(%d)", int32(t))
+}
+
+// Codec converts between the raw buffer bytes of one SampleType and Go values.
+//
+// Integer samples are exchanged at their native resolution: an Int24 sample is
+// in [-2^23, 2^23) and an Int32LSB20 sample in [-2^19, 2^19). Float samples are
+// exchanged as 32 bit integers. Normalized floats use the range [-1, 1]; values
+// outside of it are clipped when encoding to an integer type.
+type Codec struct {
+	typ   SampleType
+	size  int  // bytes per sample
+	bits  int  // valid bits per sample
+	float bool // IEEE 754
+	order binary.ByteOrder
+}
+
+// NewCodec returns the codec of a PCM sample type. DSD types are not PCM and have no codec.
+func NewCodec(t SampleType) (Codec, error) {
+	c := Codec{typ: t, size: t.Size(), order: binary.LittleEndian}
+
+	switch t {
+	case ASIOSTInt16MSB, ASIOSTInt24MSB, ASIOSTInt32MSB, ASIOSTFloat32MSB, ASIOSTFloat64MSB,
+		ASIOSTInt32MSB16, ASIOSTInt32MSB18, ASIOSTInt32MSB20, ASIOSTInt32MSB24:
+		c.order = binary.BigEndian
+	case ASIOSTInt16LSB, ASIOSTInt24LSB, ASIOSTInt32LSB, ASIOSTFloat32LSB, ASIOSTFloat64LSB,
+		ASIOSTInt32LSB16, ASIOSTInt32LSB18, ASIOSTInt32LSB20, ASIOSTInt32LSB24:
+	default:
+		return Codec{}, fmt.Errorf("sample type %v has no PCM codec", t)
+	}
+
+	switch t {
+	case ASIOSTFloat32MSB, ASIOSTFloat32LSB, ASIOSTFloat64MSB, ASIOSTFloat64LSB:
+		c.float = true
+		c.bits = 8 * c.size
+	case ASIOSTInt32MSB16, ASIOSTInt32LSB16:
+		c.bits = 16
+	case ASIOSTInt32MSB18, ASIOSTInt32LSB18:
+		c.bits = 18
+	case ASIOSTInt32MSB20, ASIOSTInt32LSB20:
+		c.bits = 20
+	case ASIOSTInt32MSB24, ASIOSTInt32LSB24:
+		c.bits = 24
+	default:
+		c.bits = 8 * c.size
+	}
+	return c, nil
+}
+
+// SampleType returns the sample type handled by the codec.
+func (c Codec) SampleType() SampleType { return c.typ }
+
+// Size returns the number of bytes per sample.
+func (c Codec) Size() int { return c.size }
+
+// Bits returns the number of valid bits per sample.
+func (c Codec) Bits() int { return c.bits }
+
+// IsFloat reports whether samples are IEEE 754 floats.
+func (c Codec) IsFloat() bool { return c.float }
+
+// Len returns the number of whole samples in buf.
+func (c Codec) Len(buf []byte) int { return len(buf) / c.size }
+
+func (c Codec) readInt(b []byte) int32 {
+	var v int32
+	switch c.size {
+	case 2:
+		v = int32(int16(c.order.Uint16(b)))
+	case 3:
+		if c.order == binary.BigEndian {
+			v = int32(b[0])<<24 | int32(b[1])<<16 | int32(b[2])<<8
+		} else {
+			v = int32(b[2])<<24 | int32(b[1])<<16 | int32(b[0])<<8
+		}
+		v >>= 8
+	default:
+		// aligned types carry the sample in the low bits; sign extend from there
+		shift := 32 - c.bits
+		v = int32(c.order.Uint32(b)) << shift >> shift
+	}
+	return v
+}
+
+func (c Codec) writeInt(b []byte, v int32) {
+	switch c.size {
+	case 2:
+		c.order.PutUint16(b, uint16(v))
+	case 3:
+		if c.order == binary.BigEndian {
+			b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+		} else {
+			b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+		}
+	default:
+		c.order.PutUint32(b, uint32(v))
+	}
+}
+
+func (c Codec) readFloat(b []byte) float64 {
+	if c.size == 8 {
+		return math.Float64frombits(c.order.Uint64(b))
+	}
+	return float64(math.Float32frombits(c.order.Uint32(b)))
+}
+
+func (c Codec) writeFloat(b []byte, x float64) {
+	if c.size == 8 {
+		c.order.PutUint64(b, math.Float64bits(x))
+	} else {
+		c.order.PutUint32(b, math.Float32bits(float32(x)))
+	}
+}
+
+// quantize maps a normalized value to a signed integer of the given width, clipping out of range values.
+func quantize(x float64, bits int) int32 {
+	full := float64(int64(1) << (bits - 1))
+	v := math.Round(x * full)
+	switch {
+	case v >= full:
+		return int32(full - 1)
+	case v <= -full:
+		return int32(-full)
+	case v != v: // NaN
+		return 0
+	}
+	return int32(v)
+}
+
+// requantize maps a sample scaled to the full int32 range to a signed integer
+// of the given width, rounding like quantize.
+func requantize(v int32, bits int) int32 {
+	shift := 32 - bits
+	if shift == 0 {
+		return v
+	}
+	x, half := int64(v), int64(1)<<(shift-1)
+	if x >= 0 {
+		x = (x + half) >> shift
+	} else {
+		x = -((-x + half) >> shift)
+	}
+	return int32(min(x, int64(1)<<(bits-1)-1))
+}
+
+func (c Codec) scale() float64 {
+	return 1 / float64(int64(1)<<(c.bits-1))
+}
+
+// DecodeFloat64 decodes samples from src into normalized values in dst and returns the number of samples decoded.
+func (c Codec) DecodeFloat64(dst []float64, src []byte) int {
+	n := min(len(dst), c.Len(src))
+	if c.float {
+		for i := range n {
+			dst[i] = c.readFloat(src[i*c.size:])
+		}
+		return n
+	}
+	scale := c.scale()
+	for i := range n {
+		dst[i] = float64(c.readInt(src[i*c.size:])) * scale
+	}
+	return n
+}
+
+// EncodeFloat64 encodes normalized values from src into dst and returns the number of samples encoded.
+func (c Codec) EncodeFloat64(dst []byte, src []float64) int {
+	n := min(len(src), c.Len(dst))
+	if c.float {
+		for i := range n {
+			c.writeFloat(dst[i*c.size:], src[i])
+		}
+		return n
+	}
+	for i := range n {
+		c.writeInt(dst[i*c.size:], quantize(src[i], c.bits))
+	}
+	return n
+}
+
+// DecodeFloat32 decodes samples from src into normalized values in dst and returns the number of samples decoded.
+func (c Codec) DecodeFloat32(dst []float32, src []byte) int {
+	n := min(len(dst), c.Len(src))
+	if c.float {
+		for i := range n {
+			dst[i] = float32(c.readFloat(src[i*c.size:]))
+		}
+		return n
+	}
+	scale := c.scale()
+	for i := range n {
+		dst[i] = float32(float64(c.readInt(src[i*c.size:])) * scale)
+	}
+	return n
+}
+
+// EncodeFloat32 encodes normalized values from src into dst and returns the number of samples encoded.
+func (c Codec) EncodeFloat32(dst []byte, src []float32) int {
+	n := min(len(src), c.Len(dst))
+	if c.float {
+		for i := range n {
+			c.writeFloat(dst[i*c.size:], float64(src[i]))
+		}
+		return n
+	}
+	for i := range n {
+		c.writeInt(dst[i*c.size:], quantize(float64(src[i]), c.bits))
+	}
+	return n
+}
+
+// DecodeInt32 decodes samples from src into native resolution integers in dst and returns the number of samples decoded.
+func (c Codec) DecodeInt32(dst []int32, src []byte) int {
+	n := min(len(dst), c.Len(src))
+	if c.float {
+		for i := range n {
+			dst[i] = quantize(c.readFloat(src[i*c.size:]), 32)
+		}
+		return n
+	}
+	for i := range n {
+		dst[i] = c.readInt(src[i*c.size:])
+	}
+	return n
+}
+
+// EncodeInt32 encodes native resolution integers from src into dst and returns the number of samples encoded.
+// Values are clipped to the range of the sample type.
+func (c Codec) EncodeInt32(dst []byte, src []int32) int {
+	n := min(len(src), c.Len(dst))
+	if c.float {
+		for i := range n {
+			c.writeFloat(dst[i*c.size:], float64(src[i])/(1<<31))
+		}
+		return n
+	}
+	hi, lo := int32(1)<<(c.bits-1)-1, -int32(1)<<(c.bits-1)
+	if c.bits == 32 {
+		hi, lo = math.MaxInt32, math.MinInt32
+	}
+	for i := range n {
+		c.writeInt(dst[i*c.size:], min(max(src[i], lo), hi))
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package asio
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var pcmSampleTypes = []SampleType{
+	ASIOSTInt16MSB, ASIOSTInt24MSB, ASIOSTInt32MSB, ASIOSTFloat32MSB, ASIOSTFloat64MSB,
+	ASIOSTInt32MSB16, ASIOSTInt32MSB18, ASIOSTInt32MSB20, ASIOSTInt32MSB24,
+	ASIOSTInt16LSB, ASIOSTInt24LSB, ASIOSTInt32LSB, ASIOSTFloat32LSB, ASIOSTFloat64LSB,
+	ASIOSTInt32LSB16, ASIOSTInt32LSB18, ASIOSTInt32LSB20, ASIOSTInt32LSB24,
+}
+
+// testValues returns edge cases and random values within the native range of c.
+func testValues(c Codec) []int32 {
+	lo, hi := int64(-1)<<(c.Bits()-1), int64(1)<<(c.Bits()-1)-1
+	if c.IsFloat() {
+		lo, hi = math.MinInt32, math.MaxInt32
+	}
+	values := []int32{0, 1, -1, int32(lo), int32(hi), int32(lo / 2), int32(hi / 2)}
+	r := rand.New(rand.NewSource(int64(c.SampleType())))
+	for range 1000 {
+		values = append(values, int32(lo+r.Int63n(hi-lo+1)))
+	}
+	return values
+}
+
+func TestCodecInt32RoundTrip(t *testing.T) {
+	for _, st := range pcmSampleTypes {
+		c, err := NewCodec(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.IsFloat() {
+			continue // float types do not represent every 32 bit integer
+		}
+		values := testValues(c)
+		raw := make([]byte, len(values)*c.Size())
+		if n := c.EncodeInt32(raw, values); n != len(values) {
+			t.Fatalf("%v: encoded %d samples, want %d", st, n, len(values))
+		}
+		got := make([]int32, len(values))
+		if n := c.DecodeInt32(got, raw); n != len(values) {
+			t.Fatalf("%v: decoded %d samples, want %d", st, n, len(values))
+		}
+		for i := range values {
+			if got[i] != values[i] {
+				t.Fatalf("%v: sample %d: got %d, want %d", st, i, got[i], values[i])
+			}
+		}
+	}
+}
+
+func TestCodecFloatRoundTrip(t *testing.T) {
+	for _, st := range pcmSampleTypes {
+		c, err := NewCodec(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// raw -> float -> raw must be lossless for every type
+		values := testValues(c)
+		raw := make([]byte, len(values)*c.Size())
+		c.EncodeInt32(raw, values)
+
+		f64 := make([]float64, len(values))
+		c.DecodeFloat64(f64, raw)
+		raw64 := make([]byte, len(raw))
+		c.EncodeFloat64(raw64, f64)
+		if !bytes.Equal(raw, raw64) {
+			t.Errorf("%v: float64 round trip changed samples", st)
+		}
+
+		if c.Bits() <= 24 || st == ASIOSTFloat32LSB || st == ASIOSTFloat32MSB {
+			f32 := make([]float32, len(values))
+			c.DecodeFloat32(f32, raw)
+			raw32 := make([]byte, len(raw))
+			c.EncodeFloat32(raw32, f32)
+			if !bytes.Equal(raw, raw32) {
+				t.Errorf("%v: float32 round trip changed samples", st)
+			}
+		}
+
+		// float -> raw -> float must be within one quantization step
+		step := 1 / float64(int64(1)<<(c.Bits()-1))
+		if c.IsFloat() {
+			step = 1e-7
+		}
+		in := []float64{0, 0.5, -0.5, 0.25, -1, 0.999, 1e-3, -0.123456789}
+		buf := make([]byte, len(in)*c.Size())
+		c.EncodeFloat64(buf, in)
+		out := make([]float64, len(in))
+		c.DecodeFloat64(out, buf)
+		for i := range in {
+			if math.Abs(out[i]-in[i]) > step {
+				t.Errorf("%v: %v decoded as %v", st, in[i], out[i])
+			}
+		}
+	}
+}
+
+func TestCodecClipping(t *testing.T) {
+	for _, st := range pcmSampleTypes {
+		c, _ := NewCodec(st)
+		if c.IsFloat() {
+			continue
+		}
+		buf := make([]byte, 3*c.Size())
+		c.EncodeFloat32(buf, []float32{2, -2, float32(math.NaN())})
+		got := make([]int32, 3)
+		c.DecodeInt32(got, buf)
+
+		hi, lo := int32(int64(1)<<(c.Bits()-1)-1), int32(int64(-1)<<(c.Bits()-1))
+		if got[0] != hi || got[1] != lo || got[2] != 0 {
+			t.Errorf("%v: clipped to %v, want [%d %d 0]", st, got, hi, lo)
+		}
+	}
+}
+
+func TestCodecLayout(t *testing.T) {
+	for _, tc := range []struct {
+		st    SampleType
+		value int32
+		raw   []byte
+	}{
+		{ASIOSTInt16MSB, 0x1234, []byte{0x12, 0x34}},
+		{ASIOSTInt16LSB, 0x1234, []byte{0x34, 0x12}},
+		{ASIOSTInt24MSB, 0x123456, []byte{0x12, 0x34, 0x56}},
+		{ASIOSTInt24LSB, 0x123456, []byte{0x56, 0x34, 0x12}},
+		{ASIOSTInt24LSB, -2, []byte{0xfe, 0xff, 0xff}},
+		{ASIOSTInt32MSB, 0x12345678, []byte{0x12, 0x34, 0x56, 0x78}},
+		{ASIOSTInt32LSB, 0x12345678, []byte{0x78, 0x56, 0x34, 0x12}},
+		{ASIOSTInt32LSB16, -1, []byte{0xff, 0xff, 0xff, 0xff}},
+		{ASIOSTInt32LSB20, 0x7ffff, []byte{0xff, 0xff, 0x07, 0x00}},
+		{ASIOSTInt32MSB24, -0x800000, []byte{0xff, 0x80, 0x00, 0x00}},
+		{ASIOSTFloat32LSB, 1 << 30, []byte{0x00, 0x00, 0x00, 0x3f}},
+		{ASIOSTFloat64MSB, -1 << 30, []byte{0xbf, 0xe0, 0, 0, 0, 0, 0, 0}},
+	} {
+		c, err := NewCodec(tc.st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := make([]byte, c.Size())
+		c.EncodeInt32(raw, []int32{tc.value})
+		if !bytes.Equal(raw, tc.raw) {
+			t.Errorf("%v: %d encoded as % x, want % x", tc.st, tc.value, raw, tc.raw)
+		}
+		got := []int32{0}
+		c.DecodeInt32(got, tc.raw)
+		if got[0] != tc.value {
+			t.Errorf("%v: % x decoded as %d, want %d", tc.st, tc.raw, got[0], tc.value)
+		}
+	}
+}
+
+func TestCodecDSD(t *testing.T) {
+	for _, st := range []SampleType{ASIOSTDSDInt8LSB1, ASIOSTDSDInt8MSB1, ASIOSTDSDInt8NER8} {
+		if _, err := NewCodec(st); err == nil {
+			t.Errorf("%v: expected error", st)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package asio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the deployable part of a Session: which driver to load and how
+// to set it up. Package config reads it from JSON, TOML or YAML files, and
+// ApplyEnv from environment variables, so that one binary can run on machines
+// with different interfaces.
+type Config struct {
+	Driver          string   `json:"driver,omitempty" toml:"driver,omitempty" yaml:"driver,omitempty"`
+	FallbackDrivers []string `json:"fallback_drivers,omitempty" toml:"fallback_drivers,omitempty" yaml:"fallback_drivers,omitempty"`
+
+	SampleRate  float64 `json:"sample_rate,omitempty" toml:"sample_rate,omitempty" yaml:"sample_rate,omitempty"`
+	BufferSize  int     `json:"buffer_size,omitempty" toml:"buffer_size,omitempty" yaml:"buffer_size,omitempty"`
+	Latency     string  `json:"latency,omitempty" toml:"latency,omitempty" yaml:"latency,omitempty"` // such as "10ms"
+	ClockSource string  `json:"clock_source,omitempty" toml:"clock_source,omitempty" yaml:"clock_source,omitempty"`
+
+	// Channels to create buffers for, in the order the handler receives them; none selects every channel.
+	Inputs  []ChannelConfig `json:"inputs,omitempty" toml:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Outputs []ChannelConfig `json:"outputs,omitempty" toml:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// ChannelConfig selects a channel by number or by the name the driver gives it.
+// All channels of a direction must be selected the same way.
+type ChannelConfig struct {
+	Channel *int   `json:"channel,omitempty" toml:"channel,omitempty" yaml:"channel,omitempty"`
+	Name    string `json:"name,omitempty" toml:"name,omitempty" yaml:"name,omitempty"`
+
+	Label  string  `json:"label,omitempty" toml:"label,omitempty" yaml:"label,omitempty"`       // name of the channel within the application
+	GainDB float64 `json:"gain_db,omitempty" toml:"gain_db,omitempty" yaml:"gain_db,omitempty"` // trim, applied to FloatHandler samples
+}
+
+// maxGainDB bounds gain trims.
+const maxGainDB = 96
+
+// String returns the configuration as JSON.
+func (c Config) String() string {
+	b, _ := json.MarshalIndent(c, "", "  ")
+	return string(b)
+}
+
+// ApplyEnv overrides the configuration with environment variables named after
+// the keys of the file formats, in upper case and after prefix and an underscore:
+//
+//	ASIO_DRIVER=Focusrite USB ASIO
+//	ASIO_FALLBACK_DRIVERS=ASIO4ALL v2,Generic Low Latency ASIO Driver
+//	ASIO_SAMPLE_RATE=48000
+//	ASIO_BUFFER_SIZE=256
+//	ASIO_LATENCY=5ms
+//	ASIO_CLOCK_SOURCE=Internal
+//	ASIO_INPUTS=0,1
+//	ASIO_OUTPUTS=Out 1,Out 2
+//
+// Lists are separated by commas. INPUTS and OUTPUTS replace the channels of the
+// configuration with channels selected by number, or by name if any of them
+// is not a number.
+func (c *Config) ApplyEnv(prefix string) error {
+	var errs []error
+	env := func(key string, apply func(value string) error) {
+		name := prefix + "_" + key
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := apply(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	env("DRIVER", func(v string) error {
+		c.Driver = v
+		return nil
+	})
+	env("FALLBACK_DRIVERS", func(v string) error {
+		c.FallbackDrivers = splitList(v)
+		return nil
+	})
+	env("SAMPLE_RATE", func(v string) (err error) {
+		c.SampleRate, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	env("BUFFER_SIZE", func(v string) (err error) {
+		c.BufferSize, err = strconv.Atoi(v)
+		return err
+	})
+	env("LATENCY", func(v string) error {
+		c.Latency = v
+		return nil
+	})
+	env("CLOCK_SOURCE", func(v string) error {
+		c.ClockSource = v
+		return nil
+	})
+	env("INPUTS", func(v string) error {
+		c.Inputs = channelList(splitList(v))
+		return nil
+	})
+	env("OUTPUTS", func(v string) error {
+		c.Outputs = channelList(splitList(v))
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma separated list, ignoring empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// channelList selects channels by number, or by name if any of the entries is not a number.
+func channelList(entries []string) []ChannelConfig {
+	channels := make([]ChannelConfig, len(entries))
+	for i, entry := range entries {
+		n, err := strconv.Atoi(entry)
+		if err != nil {
+			for i, entry := range entries {
+				channels[i] = ChannelConfig{Name: entry}
+			}
+			return channels
+		}
+		channels[i] = ChannelConfig{Channel: &n}
+	}
+	return channels
+}
+
+// Validate checks the configuration, reporting every invalid key at once.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Driver == "" {
+		invalid("driver", "missing")
+	}
+	for i, name := range c.FallbackDrivers {
+		if name == "" {
+			invalid(fmt.Sprintf("fallback_drivers[%d]", i), "empty name")
+		}
+	}
+	if c.SampleRate < 0 || math.IsNaN(c.SampleRate) || math.IsInf(c.SampleRate, 0) {
+		invalid("sample_rate", "invalid rate %v", c.SampleRate)
+	}
+	if c.BufferSize < 0 {
+		invalid("buffer_size", "must not be negative")
+	}
+	if c.Latency != "" {
+		if latency, err := time.ParseDuration(c.Latency); err != nil {
+			invalid("latency", "%v", err)
+		} else if latency <= 0 {
+			invalid("latency", "must be positive")
+		}
+		if c.BufferSize != 0 {
+			invalid("latency", "cannot be set together with buffer_size")
+		}
+	}
+
+	for _, dir := range []struct {
+		key      string
+		channels []ChannelConfig
+	}{
+		{"inputs", c.Inputs},
+		{"outputs", c.Outputs},
+	} {
+		labels := map[string]bool{}
+		byName := 0
+		for i, ch := range dir.channels {
+			key := fmt.Sprintf("%s[%d]", dir.key, i)
+			switch {
+			case ch.Channel == nil && ch.Name == "":
+				invalid(key, "channel or name required")
+			case ch.Channel != nil && ch.Name != "":
+				invalid(key, "channel and name are exclusive")
+			case ch.Channel != nil && *ch.Channel < 0:
+				invalid(key+".channel", "invalid channel %d", *ch.Channel)
+			}
+			if ch.Name != "" {
+				byName++
+			}
+			if ch.Label != "" {
+				if labels[ch.Label] {
+					invalid(key+".label", "duplicate label %q", ch.Label)
+				}
+				labels[ch.Label] = true
+			}
+			if math.IsNaN(ch.GainDB) || math.Abs(ch.GainDB) > maxGainDB {
+				invalid(key+".gain_db", "must be within ±%d dB", maxGainDB)
+			}
+		}
+		if byName != 0 && byName != len(dir.channels) {
+			invalid(dir.key, "channels must all be selected by channel or all by name")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Session returns a session set up as configured, to be completed with a handler.
+// Gain trims are only applied to a FloatHandler.
+func (c Config) Session() (Session, error) {
+	if err := c.Validate(); err != nil {
+		return Session{}, fmt.Errorf("config: %w", err)
+	}
+	s := Session{
+		DriverName:          c.Driver,
+		FallbackDriverNames: slices.Clone(c.FallbackDrivers),
+		SampleRate:          c.SampleRate,
+		ClockSourceName:     c.ClockSource,
+		BufferSize:          c.BufferSize,
+	}
+	if c.Latency != "" {
+		s.Latency, _ = time.ParseDuration(c.Latency)
+	}
+	s.InputChannels, s.InputChannelNames, s.InputGains = c.selection(c.Inputs)
+	s.OutputChannels, s.OutputChannelNames, s.OutputGains = c.selection(c.Outputs)
+	return s, nil
+}
+
+// selection converts channels to a selection of Session, with gains if any is not 0 dB.
+func (c Config) selection(channels []ChannelConfig) (numbers []int, names []string, gains []float64) {
+	trimmed := false
+	for _, ch := range channels {
+		if ch.Channel != nil {
+			numbers = append(numbers, *ch.Channel)
+		} else {
+			names = append(names, ch.Name)
+		}
+		gains = append(gains, math.Pow(10, ch.GainDB/20))
+		trimmed = trimmed || ch.GainDB != 0
+	}
+	if !trimmed {
+		gains = nil
+	}
+	return numbers, names, gains
+}
+
+// Input returns the index of the input channel with the given label in the
+// buffers passed to the handler, or -1 if there is none.
+func (c Config) Input(label string) int {
+	return slices.IndexFunc(c.Inputs, func(ch ChannelConfig) bool { return ch.Label == label })
+}
+
+// Output returns the index of the output channel with the given label in the
+// buffers passed to the handler, or -1 if there is none.
+func (c Config) Output(label string) int {
+	return slices.IndexFunc(c.Outputs, func(ch ChannelConfig) bool { return ch.Label == label })
+}
+
+// Config captures the driver's current settings and every channel by number,
+// as a starting point for a configuration file. Channels are labeled with the
+// names the driver gives them, except for empty names and names shared by
+// several channels of the same direction, since labels must be unique.
+func (dev *Device) Config() (Config, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("Config")
+	if err != nil {
+		return Config{}, err
+	}
+	c := Config{Driver: dev.driverName}
+	if c.SampleRate, err = drv.GetSampleRate(); err != nil {
+		return Config{}, dev.driverError("GetSampleRate", err)
+	}
+	_, _, c.BufferSize, _, err = drv.GetBufferSize()
+	if err != nil {
+		return Config{}, dev.driverError("GetBufferSize", err)
+	}
+	// not every driver has clock sources
+	if sources, err := drv.GetClockSources(); err == nil {
+		for _, source := range sources {
+			if source.IsCurrentSource {
+				c.ClockSource = source.Name
+			}
+		}
+	}
+
+	numInputs, numOutputs, err := drv.GetChannels()
+	if err != nil {
+		return Config{}, dev.driverError("GetChannels", err)
+	}
+	logger := dev.logger()
+	channels := func(n int, isInput bool) []ChannelConfig {
+		names := make([]string, n)
+		count := map[string]int{}
+		for i := range n {
+			names[i] = channelInfo(drv, logger, i, isInput).Name
+			count[names[i]]++
+		}
+		var channels []ChannelConfig
+		for i, name := range names {
+			ch := ChannelConfig{Channel: &i}
+			if name != "" && count[name] == 1 {
+				ch.Label = name
+			}
+			channels = append(channels, ch)
+		}
+		return channels
+	}
+	c.Inputs = channels(numInputs, true)
+	c.Outputs = channels(numOutputs, false)
+	return c, nil
+}
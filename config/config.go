@@ -0,0 +1,124 @@
+// Package config reads and writes asio.Config files in JSON, TOML or YAML.
+// It is apart from package asio so that programs not loading configuration
+// files do not depend on the TOML and YAML libraries.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/xsjk/go-asio"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a configuration file.
+type Format string
+
+const (
+	JSON Format = "json"
+	TOML Format = "toml"
+	YAML Format = "yaml"
+)
+
+// FormatOf returns the format of a configuration file from its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".toml":
+		return TOML, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	}
+	return "", fmt.Errorf("config %s: unknown format, expected .json, .toml, .yaml or .yml", path)
+}
+
+// Load reads the configuration file at path, if path is not empty, then
+// applies the environment variables starting with envPrefix, if it is not empty,
+// and validates the result.
+func Load(path, envPrefix string) (asio.Config, error) {
+	var c asio.Config
+	if path != "" {
+		format, err := FormatOf(path)
+		if err != nil {
+			return asio.Config{}, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return asio.Config{}, fmt.Errorf("config: %w", err)
+		}
+		defer f.Close()
+		if c, err = Decode(f, format); err != nil {
+			return asio.Config{}, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	if envPrefix != "" {
+		if err := c.ApplyEnv(envPrefix); err != nil {
+			return asio.Config{}, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return asio.Config{}, fmt.Errorf("config: %w", err)
+	}
+	return c, nil
+}
+
+// Decode reads a configuration. Unknown keys are errors, so that typos do not go unnoticed.
+// The configuration is not validated.
+func Decode(r io.Reader, format Format) (asio.Config, error) {
+	var c asio.Config
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return asio.Config{}, err
+		}
+		if dec.More() {
+			return asio.Config{}, errors.New("data after the configuration")
+		}
+	case TOML:
+		md, err := toml.NewDecoder(r).Decode(&c)
+		if err != nil {
+			return asio.Config{}, err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return asio.Config{}, fmt.Errorf("unknown keys %q", undecoded)
+		}
+	case YAML:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&c); err != nil && err != io.EOF {
+			return asio.Config{}, err
+		}
+	default:
+		return asio.Config{}, fmt.Errorf("unknown config format %q", format)
+	}
+	return c, nil
+}
+
+// Encode writes a configuration, such as the effective one after Load.
+func Encode(w io.Writer, c asio.Config, format Format) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	case TOML:
+		return toml.NewEncoder(w).Encode(c)
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown config format %q", format)
+}
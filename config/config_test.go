@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xsjk/go-asio"
+)
+
+func channel(n int) *int { return &n }
+
+var testConfig = asio.Config{
+	Driver:          "Focusrite USB ASIO",
+	FallbackDrivers: []string{"ASIO4ALL v2"},
+	SampleRate:      48000,
+	Latency:         "5ms",
+	ClockSource:     "Internal",
+	Inputs: []asio.ChannelConfig{
+		{Channel: channel(0), Label: "mic"},
+		{Channel: channel(3), Label: "guitar", GainDB: 6},
+	},
+	Outputs: []asio.ChannelConfig{
+		{Name: "Monitor L", Label: "left"},
+		{Name: "Monitor R", Label: "right", GainDB: -3.5},
+	},
+}
+
+func TestFormats(t *testing.T) {
+	docs := map[Format]string{
+		JSON: `{
+			"driver": "Focusrite USB ASIO",
+			"fallback_drivers": ["ASIO4ALL v2"],
+			"sample_rate": 48000,
+			"latency": "5ms",
+			"clock_source": "Internal",
+			"inputs": [{"channel": 0, "label": "mic"}, {"channel": 3, "label": "guitar", "gain_db": 6}],
+			"outputs": [{"name": "Monitor L", "label": "left"}, {"name": "Monitor R", "label": "right", "gain_db": -3.5}]
+		}`,
+		TOML: `
+			driver = "Focusrite USB ASIO"
+			fallback_drivers = ["ASIO4ALL v2"]
+			sample_rate = 48000.0
+			latency = "5ms"
+			clock_source = "Internal"
+
+			[[inputs]]
+			channel = 0
+			label = "mic"
+			[[inputs]]
+			channel = 3
+			label = "guitar"
+			gain_db = 6.0
+
+			[[outputs]]
+			name = "Monitor L"
+			label = "left"
+			[[outputs]]
+			name = "Monitor R"
+			label = "right"
+			gain_db = -3.5
+		`,
+		YAML: `
+driver: Focusrite USB ASIO
+fallback_drivers: [ASIO4ALL v2]
+sample_rate: 48000
+latency: 5ms
+clock_source: Internal
+inputs:
+  - {channel: 0, label: mic}
+  - {channel: 3, label: guitar, gain_db: 6}
+outputs:
+  - {name: Monitor L, label: left}
+  - {name: Monitor R, label: right, gain_db: -3.5}
+`,
+	}
+	for format, doc := range docs {
+		c, err := Decode(strings.NewReader(doc), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(c, testConfig) {
+			t.Errorf("%s: decoded %v", format, c)
+		}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, testConfig, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if c, err := Decode(&buf, format); err != nil || !reflect.DeepEqual(c, testConfig) {
+			t.Errorf("%s: encoded and decoded to %v, %v", format, c, err)
+		}
+
+		unknown := strings.Replace(doc, "clock_source", "clock_sorce", 1)
+		if _, err := Decode(strings.NewReader(unknown), format); err == nil || !strings.Contains(err.Error(), "clock_sorce") {
+			t.Errorf("%s: unknown key: %v", format, err)
+		}
+	}
+
+	if c, err := Decode(strings.NewReader(""), YAML); err != nil || !reflect.DeepEqual(c, asio.Config{}) {
+		t.Errorf("empty YAML = %v, %v", c, err)
+	}
+	if _, err := FormatOf("asio.ini"); err == nil {
+		t.Error("format of .ini file")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asio.yml")
+	if err := os.WriteFile(path, []byte("driver: ASIO4ALL v2\nsample_rate: 44100\ninputs: [{channel: 0}]\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ASIO_DRIVER", "Focusrite USB ASIO")
+	t.Setenv("TEST_ASIO_FALLBACK_DRIVERS", "ASIO4ALL v2, ,Generic Low Latency ASIO Driver")
+	t.Setenv("TEST_ASIO_BUFFER_SIZE", "128")
+	t.Setenv("TEST_ASIO_OUTPUTS", "Out 1,Out 2")
+
+	c, err := Load(path, "TEST_ASIO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := asio.Config{
+		Driver:          "Focusrite USB ASIO",
+		FallbackDrivers: []string{"ASIO4ALL v2", "Generic Low Latency ASIO Driver"},
+		SampleRate:      44100,
+		BufferSize:      128,
+		Inputs:          []asio.ChannelConfig{{Channel: channel(0)}},
+		Outputs:         []asio.ChannelConfig{{Name: "Out 1"}, {Name: "Out 2"}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("loaded %v, want %v", c, want)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json"), ""); err == nil {
+		t.Error("loaded a missing file")
+	}
+	t.Setenv("TEST_ASIO_SAMPLE_RATE", "fast")
+	if _, err := Load(path, "TEST_ASIO"); err == nil || !strings.Contains(err.Error(), "TEST_ASIO_SAMPLE_RATE") {
+		t.Errorf("invalid environment variable: %v", err)
+	}
+	t.Setenv("TEST_ASIO_SAMPLE_RATE", "-1")
+	if _, err := Load(path, "TEST_ASIO"); err == nil || !strings.Contains(err.Error(), "sample_rate") {
+		t.Errorf("invalid configuration: %v", err)
+	}
+}
+
+func TestDeviceConfig(t *testing.T) {
+	// drivers often name channels alike, or not at all
+	sim := asio.NewSimDriver(asio.SimConfig{
+		InputChannels:  3,
+		OutputChannels: 2,
+		InputNames:     []string{"In", "In", "Mic"},
+		OutputNames:    []string{"", "Out"},
+	})
+	var device asio.Device
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	c, err := device.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{JSON, TOML, YAML} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, c, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		decoded, err := Decode(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		s, err := decoded.Session()
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if err := device.OpenWith(asio.OpenOptions{
+			InputChannels:      s.InputChannels,
+			OutputChannels:     s.OutputChannels,
+			InputChannelNames:  s.InputChannelNames,
+			OutputChannelNames: s.OutputChannelNames,
+		}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for dir, channels := range map[string][]asio.ChannelInfo{
+			"inputs":  device.InputChannels(),
+			"outputs": device.OutputChannels(),
+		} {
+			var got []int
+			for _, ch := range channels {
+				got = append(got, ch.Channel)
+			}
+			if want := map[string][]int{"inputs": {0, 1, 2}, "outputs": {0, 1}}[dir]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: opened %s %v, want %v", format, dir, got, want)
+			}
+		}
+		if decoded.Input("Mic") != 2 || decoded.Output("Out") != 1 {
+			t.Errorf("%s: labels of %v", format, decoded)
+		}
+		if err := device.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
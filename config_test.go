@@ -0,0 +1,205 @@
+package asio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func channel(n int) *int { return &n }
+
+var testConfig = Config{
+	Driver:          "Focusrite USB ASIO",
+	FallbackDrivers: []string{"ASIO4ALL v2"},
+	SampleRate:      48000,
+	Latency:         "5ms",
+	ClockSource:     "Internal",
+	Inputs: []ChannelConfig{
+		{Channel: channel(0), Label: "mic"},
+		{Channel: channel(3), Label: "guitar", GainDB: 6},
+	},
+	Outputs: []ChannelConfig{
+		{Name: "Monitor L", Label: "left"},
+		{Name: "Monitor R", Label: "right", GainDB: -3.5},
+	},
+}
+
+func TestConfigApplyEnv(t *testing.T) {
+	c := Config{Driver: "ASIO4ALL v2", SampleRate: 44100, Inputs: []ChannelConfig{{Channel: channel(0)}}}
+	t.Setenv("TEST_ASIO_DRIVER", "Focusrite USB ASIO")
+	t.Setenv("TEST_ASIO_FALLBACK_DRIVERS", "ASIO4ALL v2, ,Generic Low Latency ASIO Driver")
+	t.Setenv("TEST_ASIO_BUFFER_SIZE", "128")
+	t.Setenv("TEST_ASIO_OUTPUTS", "Out 1,Out 2")
+	if err := c.ApplyEnv("TEST_ASIO"); err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		Driver:          "Focusrite USB ASIO",
+		FallbackDrivers: []string{"ASIO4ALL v2", "Generic Low Latency ASIO Driver"},
+		SampleRate:      44100,
+		BufferSize:      128,
+		Inputs:          []ChannelConfig{{Channel: channel(0)}},
+		Outputs:         []ChannelConfig{{Name: "Out 1"}, {Name: "Out 2"}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("applied %v, want %v", c, want)
+	}
+
+	t.Setenv("TEST_ASIO_INPUTS", "2,0")
+	t.Setenv("TEST_ASIO_SAMPLE_RATE", "fast")
+	t.Setenv("TEST_ASIO_BUFFER_SIZE", "large")
+	err := c.ApplyEnv("TEST_ASIO")
+	for _, name := range []string{"TEST_ASIO_SAMPLE_RATE: ", "TEST_ASIO_BUFFER_SIZE: "} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s not reported: %v", name, err)
+		}
+	}
+	if want := []ChannelConfig{{Channel: channel(2)}, {Channel: channel(0)}}; !reflect.DeepEqual(c.Inputs, want) {
+		t.Errorf("inputs %v, want %v", c.Inputs, want)
+	}
+	if s := c.String(); !strings.HasPrefix(s, "{\n  \"driver\": \"Focusrite USB ASIO\",") || strings.HasSuffix(s, "\n") {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := testConfig.Validate(); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+
+	err := Config{
+		FallbackDrivers: []string{""},
+		SampleRate:      math.NaN(),
+		BufferSize:      256,
+		Latency:         "soon",
+		Inputs: []ChannelConfig{
+			{Channel: channel(-1)},
+			{Name: "In 2", Label: "a"},
+			{Label: "a", GainDB: 200},
+		},
+		Outputs: []ChannelConfig{{Channel: channel(0), Name: "Out 1"}},
+	}.Validate()
+	for _, key := range []string{
+		"driver", "fallback_drivers[0]", "sample_rate", "latency", "inputs[0].channel",
+		"inputs[2]", "inputs[2].label", "inputs[2].gain_db", "inputs", "outputs[0]",
+	} {
+		if err == nil || !strings.Contains(err.Error(), "\n"+key+": ") && !strings.HasPrefix(err.Error(), key+": ") {
+			t.Errorf("%s not reported: %v", key, err)
+		}
+	}
+}
+
+func TestConfigSession(t *testing.T) {
+	s, err := testConfig.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Session{
+		DriverName:          "Focusrite USB ASIO",
+		FallbackDriverNames: []string{"ASIO4ALL v2"},
+		SampleRate:          48000,
+		Latency:             5 * time.Millisecond,
+		ClockSourceName:     "Internal",
+		InputChannels:       []int{0, 3},
+		InputGains:          []float64{1, math.Pow(10, 6.0/20)},
+		OutputChannelNames:  []string{"Monitor L", "Monitor R"},
+		OutputGains:         []float64{1, math.Pow(10, -3.5/20)},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("session %+v, want %+v", s, want)
+	}
+	if testConfig.Input("guitar") != 1 || testConfig.Output("left") != 0 || testConfig.Output("mic") != -1 {
+		t.Error("wrong channel labels")
+	}
+
+	// gains are applied around the handler
+	var mu sync.Mutex
+	var played [2]float32
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  4,
+		OutputChannels: 2,
+		SampleType:     ASIOSTFloat32LSB,
+		OutputNames:    []string{"Monitor L", "Monitor R"},
+		RealTime:       true,
+		OnInput: func(_ int, in [][]byte) {
+			for _, buf := range in {
+				for i := 0; i < len(buf); i += 4 {
+					binary.LittleEndian.PutUint32(buf[i:], math.Float32bits(0.25))
+				}
+			}
+		},
+		OnOutput: func(_ int, out [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i, buf := range out {
+				played[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf))
+			}
+		},
+	})
+	s.DriverName, s.FallbackDriverNames, s.Driver = "", nil, sim
+	s.Latency, s.SampleRate = 0, 0
+	s.StopAfterFrames = 1024
+	s.FloatHandler = func(in, out [][]float32) {
+		copy(out[0], in[0])
+		copy(out[1], in[1])
+	}
+	if err := s.RunContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, want := range []float64{0.25, 0.25 * math.Pow(10, 6.0/20) * math.Pow(10, -3.5/20)} {
+		if math.Abs(float64(played[i])-want) > 1e-6 {
+			t.Errorf("output %d played %v, want %v", i, played[i], want)
+		}
+	}
+
+	s.OutputGains = s.OutputGains[:1]
+	if err := s.RunContext(context.Background()); err == nil || !strings.Contains(err.Error(), "OutputGains: ") {
+		t.Errorf("gains of the wrong length: %v", err)
+	}
+}
+
+func TestDeviceConfig(t *testing.T) {
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  2,
+		OutputChannels: 1,
+		PreferredSize:  128,
+		SampleRates:    []float64{48000, 96000},
+		ClockSources:   []string{"Internal", "ADAT"},
+		InputNames:     []string{"Mic"},
+	})
+	var device Device
+	if _, err := device.Config(); err == nil {
+		t.Error("config of a device without driver")
+	}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	sim.SetClockSource(1)
+
+	c, err := device.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		Driver:      "ASIO Simulator",
+		SampleRate:  48000,
+		BufferSize:  128,
+		ClockSource: "ADAT",
+		Inputs:      []ChannelConfig{{Channel: channel(0), Label: "Mic"}, {Channel: channel(1), Label: "In 2"}},
+		Outputs:     []ChannelConfig{{Channel: channel(0), Label: "Out 1"}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("captured %v, want %v", c, want)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,6 +2,12 @@ package asio
 
 import (
 	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -26,247 +32,841 @@ const (
 )
 
 type Device struct {
-	driver     *ASIODriver
-	io_handler func(
+	// Logger receives diagnostics; nil discards them.
+	Logger *slog.Logger
+	// ResetDebounce is how long reset requests from the driver are collected
+	// before the device is reset once; zero selects 50ms.
+	ResetDebounce time.Duration
+
+	// mu guards the state and serializes the lifecycle methods, which the
+	// reset supervisor calls from its own goroutine. It is released while the
+	// driver stops, see unlocked, so that handlers can call the device.
+	mu       sync.Mutex
+	state    State
+	stopping chan struct{} // closed when the device is no longer StateStopping
+	reset    resetSupervisor
+	options  OpenOptions // of the last successful OpenWith, for resets
+
+	driver        *ASIODriver // registry entry, when loaded by name
+	drv           Driver
+	coInitialized bool
+	driverName    string
+	io_handler    func(
 		inputChannelData [][]int32,
 		outputChannelData [][]int32,
+		timeInfo TimeInfo,
+	)
+	float_handler func(
+		inputChannelData [][]float32,
+		outputChannelData [][]float32,
+		timeInfo TimeInfo,
+	)
+	dsd_handler func(
+		inputChannelData []DSDBuffer,
+		outputChannelData []DSDBuffer,
+		timeInfo TimeInfo,
 	)
+	subscribersMu     sync.Mutex
+	subscribers       []chan Event
+	codecErr          error         // why float_handler cannot be used with the open buffers
+	channels          []ChannelInfo // channels with buffers: inputs, then outputs
+	numInputs         int
+	bufferSize        int
 	currentSampleRate float64
+	clockSource       int  // set by SetClockSource, for resets
+	clockSourceSet    bool // SetClockSource was called
+
+	// driverLogger is the logger tagged with the loaded driver's name, read
+	// without dev.mu from the driver's callbacks; nil without driver.
+	driverLogger atomic.Pointer[slog.Logger]
+}
+
+// logger returns the device's logger, tagged with the loaded driver's name.
+// It does not need dev.mu.
+func (dev *Device) logger() *slog.Logger {
+	if logger := dev.driverLogger.Load(); logger != nil {
+		return logger
+	}
+	return loggerOrDiscard(dev.Logger)
 }
 
-func (dev *Device) getDriver() (*IASIO, error) {
-	if drv := dev.driver.ASIO; drv == nil {
-		return nil, fmt.Errorf("driver not loaded")
-	} else {
-		return drv, nil
+// lock locks dev.mu for a lifecycle method, once the device is no longer
+// StateStopping.
+func (dev *Device) lock() {
+	dev.mu.Lock()
+	for dev.stopping != nil {
+		stopping := dev.stopping
+		dev.mu.Unlock()
+		<-stopping
+		dev.mu.Lock()
 	}
 }
 
+// unlocked calls a method of the driver waiting for its buffer callback to
+// return, such as Stop, with dev.mu released: the handler may call the device
+// meanwhile. The device is StateStopping until f returns, and then back in its
+// previous state. dev.mu must be held, by lock.
+func (dev *Device) unlocked(f func() error) error {
+	state, stopping := dev.state, make(chan struct{})
+	dev.state, dev.stopping = StateStopping, stopping
+	dev.mu.Unlock()
+	defer func() {
+		dev.mu.Lock()
+		dev.state, dev.stopping = state, nil
+		close(stopping)
+	}()
+	return f()
+}
+
+// getDriver returns the initialized driver, or a StateError for op when there is none. dev.mu must be held.
+func (dev *Device) getDriver(op string) (Driver, error) {
+	if err := dev.require(op, StateInitialized, StatePrepared, StateRunning, StateStopping); err != nil {
+		return nil, err
+	}
+	return dev.drv, nil
+}
+
+// driverError attributes an error of the driver call op to the device's driver; see wrapError.
+func (dev *Device) driverError(op string, err error) error {
+	return wrapError(op, dev.driverName, err)
+}
+
+// Load looks up a registered ASIO driver by name, instantiates and initializes it.
 func (dev *Device) Load(name string) error {
+	dev.lock()
+	defer dev.mu.Unlock()
 
-	CoInitialize(0)
+	if err := dev.require("Load", StateUnloaded); err != nil {
+		return err
+	}
 
-	drivers, err := ListDrivers()
+	coInitialize()
+	dev.coInitialized = true
+
+	drivers, err := listDrivers(dev.logger())
 	if err != nil {
+		dev.unload()
 		return err
 	}
 
 	dev.driver = drivers[name]
 	if dev.driver == nil {
+		dev.unload()
 		return fmt.Errorf("driver not found: %s", name)
 	}
+	dev.state = StateLoaded
+
+	if err = dev.driver.Open(); err != nil {
+		dev.unload()
+		return err
+	}
+	dev.attach(dev.driver.ASIO)
+	return nil
+}
+
+// LoadDriver initializes an already instantiated driver and uses it for this device.
+// Any Driver implementation is accepted, which allows running without COM.
+func (dev *Device) LoadDriver(drv Driver) error {
+	dev.lock()
+	defer dev.mu.Unlock()
+
+	if err := dev.require("LoadDriver", StateUnloaded); err != nil {
+		return err
+	}
+	if drv == nil {
+		return fmt.Errorf("driver is nil")
+	}
+	if ok := drv.Init(uintptr(0)); !ok {
+		return initError(drv.GetDriverName(), drv)
+	}
+	dev.attach(drv)
+	return nil
+}
 
-	return dev.driver.Open()
+func (dev *Device) attach(drv Driver) {
+	dev.drv = drv
+	dev.state = StateInitialized
+	dev.driverName = drv.GetDriverName()
+	dev.driverLogger.Store(loggerOrDiscard(dev.Logger).With("driver", dev.driverName))
+	dev.logger().Info("driver loaded", "version", drv.GetDriverVersion())
+	dev.startSupervisor()
 }
 
+// Unload stops the driver, disposes its buffers and releases it, whatever the state of the device.
 func (dev *Device) Unload() {
+	dev.stopSupervisor() // before locking: a reset in progress holds dev.mu
+
+	dev.lock()
+	defer dev.mu.Unlock()
+	dev.unload()
+}
+
+func (dev *Device) unload() {
+	if err := dev.close(); err != nil {
+		dev.logger().Warn("buffers not disposed on unload", "err", err)
+	}
 
 	if dev.driver != nil {
 		dev.driver.Close()
+		dev.driver = nil
 	}
+	dev.drv = nil
+	dev.driverLogger.Store(nil)
+	dev.state = StateUnloaded
+	dev.currentSampleRate, dev.clockSourceSet = 0, false
 
-	CoUninitialize()
+	if dev.coInitialized {
+		coUninitialize()
+		dev.coInitialized = false
+	}
 }
 
 func (dev *Device) CanSampleRate(rate float64) error {
-	drv, err := dev.getDriver()
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("CanSampleRate")
 	if err != nil {
 		return err
 	}
-	return drv.CanSampleRate(rate)
+	return dev.driverError("CanSampleRate", drv.CanSampleRate(rate))
 }
 
 func (dev *Device) GetSampleRate() (float64, error) {
-	drv, err := dev.getDriver()
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("GetSampleRate")
 	if err != nil {
 		return 0, err
 	}
 	rate, err := drv.GetSampleRate()
 	dev.currentSampleRate = rate
-	return rate, err
+	return rate, dev.driverError("GetSampleRate", err)
+}
+
+// GetLatencies returns the input and output latencies in samples. They are only valid after Open.
+func (dev *Device) GetLatencies() (inputLatency, outputLatency int, err error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("GetLatencies")
+	if err != nil {
+		return 0, 0, err
+	}
+	inputLatency, outputLatency, err = drv.GetLatencies()
+	return inputLatency, outputLatency, dev.driverError("GetLatencies", err)
 }
 
 func (dev *Device) SetSampleRate(rate float64) error {
-	drv, err := dev.getDriver()
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("SetSampleRate")
 	if err != nil {
 		return err
 	}
 	err = drv.SetSampleRate(rate)
 	if err != nil {
-		return err
+		return dev.driverError("SetSampleRate", err)
 	}
 	dev.currentSampleRate = rate
 	return nil
 }
 
-func (dev *Device) Open() error {
-	drv, err := dev.getDriver()
+// GetClockSources returns the clocks the driver can synchronize to.
+func (dev *Device) GetClockSources() ([]ClockSource, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("GetClockSources")
+	if err != nil {
+		return nil, err
+	}
+	sources, err := drv.GetClockSources()
+	return sources, dev.driverError("GetClockSources", err)
+}
+
+// SetClockSource selects the clock source with the given ClockSource.Index.
+// The selection is restored when the driver is reinitialized on reset.
+func (dev *Device) SetClockSource(index int) error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("SetClockSource")
 	if err != nil {
 		return err
 	}
+	if err = drv.SetClockSource(index); err != nil {
+		return dev.driverError("SetClockSource", err)
+	}
+	dev.clockSource, dev.clockSourceSet = index, true
+	dev.logger().Info("clock source set", "index", index)
+	return nil
+}
 
-	n_in, n_out, err := drv.GetChannels()
+// GetSamplePosition returns the sample position and the system time in
+// nanoseconds at which it was reached. It fails with ErrorSPNotAdvancing
+// unless the device is running.
+func (dev *Device) GetSamplePosition() (samplePosition, timeStamp int64, err error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("GetSamplePosition")
+	if err != nil {
+		return 0, 0, err
+	}
+	samplePosition, timeStamp, err = drv.GetSamplePosition()
+	return samplePosition, timeStamp, dev.driverError("GetSamplePosition", err)
+}
+
+// Future calls a driver extension; see Driver.Future.
+func (dev *Device) Future(selector FutureSelector, opt unsafe.Pointer) error {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver("Future")
 	if err != nil {
 		return err
 	}
-	fmt.Printf("getChannels():        %d, %d\n", n_in, n_out)
+	return dev.driverError("Future", drv.Future(selector, opt))
+}
 
-	rawInBuffers := make([][]int32, n_in)
-	rawOutBuffers := make([][]int32, n_out)
+// OpenOptions configures the buffers created by Device.OpenWith.
+type OpenOptions struct {
+	// Channels to create buffers for, by channel number. The handler receives
+	// them in this order. nil selects every channel, an empty slice none.
+	InputChannels  []int
+	OutputChannels []int
+
+	// Channels to create buffers for, by name, as an alternative to InputChannels/OutputChannels.
+	InputChannelNames  []string
+	OutputChannelNames []string
+
+	// Buffer size in samples. The closest size the driver supports is used.
+	// Zero selects the driver's preferred size.
+	BufferSize int
+	// Latency is an alternative to BufferSize: the buffer size closest to this
+	// duration at the current sample rate is used.
+	Latency time.Duration
+}
+
+// Open creates buffers for every channel of the driver.
+func (dev *Device) Open() error {
+	return dev.OpenWith(OpenOptions{})
+}
+
+// OpenWith creates buffers for the channels selected by opts.
+func (dev *Device) OpenWith(opts OpenOptions) error {
+	dev.lock()
+	defer dev.mu.Unlock()
+	return dev.openWith(opts)
+}
+
+func (dev *Device) openWith(opts OpenOptions) error {
+	if err := dev.require("Open", StateInitialized); err != nil {
+		return err
+	}
+	drv := dev.drv
+
+	n_in, n_out, err := drv.GetChannels()
+	if err != nil {
+		return dev.driverError("GetChannels", err)
+	}
+	logger := dev.logger()
+	logger.Debug("channels", "inputs", n_in, "outputs", n_out)
 
 	// getBufferSize
 	minSize, maxSize, preferredSize, granularity, err := drv.GetBufferSize()
 	if err != nil {
-		return err
+		return dev.driverError("GetBufferSize", err)
 	}
-	fmt.Printf("getBufferSize():      %d, %d, %d, %d\n", minSize, maxSize, preferredSize, granularity)
+	logger.Debug("buffer sizes", "min", minSize, "max", maxSize, "preferred", preferredSize, "granularity", granularity)
 
-	// canSampleRate
+	bufferSize := preferredSize
+	switch {
+	case opts.BufferSize != 0 && opts.Latency != 0:
+		return fmt.Errorf("both buffer size and latency requested")
+	case opts.BufferSize != 0:
+		bufferSize = resolveBufferSize(minSize, maxSize, preferredSize, granularity, opts.BufferSize)
+	case opts.Latency != 0:
+		rate, err := drv.GetSampleRate()
+		if err != nil {
+			return dev.driverError("GetSampleRate", err)
+		}
+		requested := int(math.Round(opts.Latency.Seconds() * rate))
+		bufferSize = resolveBufferSize(minSize, maxSize, preferredSize, granularity, requested)
+	}
+	logger.Debug("buffer size selected", "buffer_size", bufferSize)
 
 	// getChannelInfo (for N)
-	bufferDescriptors := make([]BufferInfo, 0, n_in+n_out)
+	allInputs := make([]ChannelInfo, 0, n_in)
 	for i := range n_in {
-		bufferDescriptors = append(bufferDescriptors, BufferInfo{
-			Channel: i,
-			IsInput: true,
-		})
-		cinfo, err := drv.GetChannelInfo(i, true)
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			continue
-		}
-		fmt.Printf(" IN%-2d: active=%v, group=%d, type=%d, name=%s\n",
-			i+1, cinfo.IsActive, cinfo.ChannelGroup, cinfo.SampleType, cinfo.Name)
+		allInputs = append(allInputs, channelInfo(drv, logger, i, true))
 	}
+	allOutputs := make([]ChannelInfo, 0, n_out)
 	for i := range n_out {
-		bufferDescriptors = append(bufferDescriptors, BufferInfo{
-			Channel: i,
-			IsInput: false,
-		})
-		cinfo, err := drv.GetChannelInfo(i, false)
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			continue
+		allOutputs = append(allOutputs, channelInfo(drv, logger, i, false))
+	}
+
+	inputs, err := selectChannels(allInputs, opts.InputChannels, opts.InputChannelNames)
+	if err != nil {
+		return err
+	}
+	outputs, err := selectChannels(allOutputs, opts.OutputChannels, opts.OutputChannelNames)
+	if err != nil {
+		return err
+	}
+	if len(inputs)+len(outputs) == 0 {
+		return fmt.Errorf("no channels selected")
+	}
+	channels := append(inputs, outputs...)
+	n_in, n_out = len(inputs), len(outputs)
+
+	rawInBuffers := make([][]int32, n_in)
+	rawOutBuffers := make([][]int32, n_out)
+	codecs := make([]Codec, 0, n_in+n_out)
+	dev.codecErr = nil
+
+	bufferDescriptors := make([]BufferInfo, len(channels))
+	for i, cinfo := range channels {
+		bufferDescriptors[i] = BufferInfo{
+			Channel: cinfo.Channel,
+			IsInput: cinfo.IsInput,
 		}
-		fmt.Printf("OUT%-2d: active=%v, group=%d, type=%d, name=%s\n",
-			i+1, cinfo.IsActive, cinfo.ChannelGroup, cinfo.SampleType, cinfo.Name)
+		codecs = append(codecs, dev.channelCodec(cinfo.SampleType))
 	}
 
-	// use minSize as buffer size for the lowest latency
-	bufferSize := preferredSize
+	// length of the []int32 view of each channel's buffer
+	int32Len := make([]int, len(channels))
+	for i, cinfo := range channels {
+		int32Len[i] = bufferSize * cinfo.SampleType.Size() / 4
+	}
 
-	// createBuffers (set callbacks)
-	err = drv.CreateBuffers(bufferDescriptors, bufferSize, Callbacks{
-		BufferSwitch: func(doubleBufferIndex int32, directProcess bool) {
+	floatInBuffers := make([][]float32, n_in)
+	for i := range floatInBuffers {
+		floatInBuffers[i] = make([]float32, bufferSize)
+	}
+	floatOutBuffers := make([][]float32, n_out)
+	for i := range floatOutBuffers {
+		floatOutBuffers[i] = make([]float32, bufferSize)
+	}
+	dsdInBuffers := make([]DSDBuffer, n_in)
+	dsdOutBuffers := make([]DSDBuffer, n_out)
+
+	process := func(doubleBufferIndex int32, timeInfo TimeInfo) {
 
+		if dev.dsd_handler != nil {
 			for i := range n_in {
-				rawInBuffers[i] = (*[(1 << 48) - 1]int32)(unsafe.Pointer(
-					bufferDescriptors[i].Buffers[doubleBufferIndex]))[:bufferSize:bufferSize]
+				dsdInBuffers[i] = DSDBuffer{typ: channels[i].SampleType,
+					data: rawBytes(bufferDescriptors[i].Buffers[doubleBufferIndex], bufferSize*channels[i].SampleType.Size())}
 			}
 			for i := range n_out {
-				rawOutBuffers[i] = (*[(1 << 48) - 1]int32)(unsafe.Pointer(
-					bufferDescriptors[i+n_in].Buffers[doubleBufferIndex]))[:bufferSize:bufferSize]
+				dsdOutBuffers[i] = DSDBuffer{typ: channels[i+n_in].SampleType,
+					data: rawBytes(bufferDescriptors[i+n_in].Buffers[doubleBufferIndex], bufferSize*channels[i+n_in].SampleType.Size())}
 			}
+			dev.dsd_handler(dsdInBuffers, dsdOutBuffers, timeInfo)
+			return
+		}
+
+		if dev.float_handler != nil {
+			for i := range n_in {
+				codecs[i].DecodeFloat32(floatInBuffers[i],
+					rawBytes(bufferDescriptors[i].Buffers[doubleBufferIndex], bufferSize*codecs[i].Size()))
+			}
+			for i := range n_out {
+				clear(floatOutBuffers[i])
+			}
+
+			dev.float_handler(floatInBuffers, floatOutBuffers, timeInfo)
 
-			if dev.io_handler != nil {
-				dev.io_handler(rawInBuffers, rawOutBuffers)
+			for i := range n_out {
+				codecs[i+n_in].EncodeFloat32(
+					rawBytes(bufferDescriptors[i+n_in].Buffers[doubleBufferIndex], bufferSize*codecs[i+n_in].Size()),
+					floatOutBuffers[i])
 			}
+			return
+		}
+
+		for i := range n_in {
+			rawInBuffers[i] = unsafe.Slice(bufferDescriptors[i].Buffers[doubleBufferIndex], int32Len[i])
+		}
+		for i := range n_out {
+			rawOutBuffers[i] = unsafe.Slice(bufferDescriptors[i+n_in].Buffers[doubleBufferIndex], int32Len[i+n_in])
+		}
+
+		if dev.io_handler != nil {
+			dev.io_handler(rawInBuffers, rawOutBuffers, timeInfo)
+		}
+	}
 
+	// createBuffers (set callbacks)
+	err = drv.CreateBuffers(bufferDescriptors, bufferSize, Callbacks{
+		BufferSwitch: func(doubleBufferIndex int32, directProcess bool) {
+			// without time info from the driver, ask for the position like the SDK's host sample does
+			var timeInfo TimeInfo
+			if pos, ts, err := drv.GetSamplePosition(); err == nil {
+				timeInfo = TimeInfo{
+					SystemTime:     ts,
+					SamplePosition: pos,
+					Flags:          TimeInfoSystemTimeValid | TimeInfoSamplePositionValid,
+				}
+			}
+			process(doubleBufferIndex, timeInfo)
 		},
 		SampleRateDidChange: func(rate float64) {
-			fmt.Printf("SampleRateDidChange(%f)\n", rate)
+			logger.Info("sample rate changed", "rate", rate)
+			dev.publish(SampleRateChanged{Rate: rate})
 		},
 		AsioMessage: func(selector, value int32, message uintptr, opt *float64) int32 {
-			fmt.Printf("AsioMessage(%d, %d)\n", selector, value)
-			switch selector {
-			case kAsioSelectorSupported:
-				switch value {
-				case kAsioResetRequest:
-				case kAsioEngineVersion:
-				case kAsioResyncRequest:
-				case kAsioLatenciesChanged:
-				case kAsioSupportsInputMonitor:
-				case kAsioOverload:
-					return 1
-				default:
-					return 0
-				}
-			case kAsioBufferSizeChange:
-				fmt.Printf("kAsioBufferSizeChange\n")
-				dev.Reset()
-				return 1
-			case kAsioResetRequest:
-				fmt.Printf("kAsioResetRequest\n")
-				dev.Reset()
-				return 1
-			case kAsioResyncRequest:
-				fmt.Printf("kAsioResyncRequest\n")
-				dev.Reset()
-				return 1
-			case kAsioLatenciesChanged:
-				fmt.Printf("kAsioLatenciesChanged\n")
-				return 1
-			case kAsioEngineVersion:
-				return 2
-			case kAsioSupportsTimeInfo:
-			case kAsioSupportsTimeCode:
-				return 0
-			case kAsioOverload:
-				fmt.Printf("kAsioOverload\n")
-				return 1
-			}
-			return 0
+			return dev.asioMessage(drv, logger, selector, value)
 		},
 		BufferSwitchTimeInfo: func(params *ASIOTime, doubleBufferIndex int32, directProcess bool) *ASIOTime {
-			fmt.Printf("BufferSwitchTimeInfo(%v, %d, %v)\n", params, doubleBufferIndex, directProcess)
+			process(doubleBufferIndex, params.TimeInfo())
 			return nil
 		}})
+	if err != nil {
+		return dev.driverError("CreateBuffers", err)
+	}
+
+	for i := range channels {
+		channels[i].IsActive = true
+	}
+	dev.channels = channels
+	dev.numInputs = n_in
+	dev.bufferSize = bufferSize
+	dev.options = opts
+	dev.state = StatePrepared
+	logger.Info("buffers created", "inputs", n_in, "outputs", n_out, "buffer_size", bufferSize)
 
-	return err
+	return nil
 }
 
-func (dev *Device) Close() error {
-	if drv, err := dev.getDriver(); err != nil {
-		return err
-	} else {
-		return drv.DisposeBuffers()
+// asioMessage answers the driver's asioMessage callback and publishes the matching events.
+func (dev *Device) asioMessage(drv Driver, logger *slog.Logger, selector, value int32) int32 {
+	logger.Debug("asio message", "selector", selector, "value", value)
+	switch selector {
+	case kAsioSelectorSupported:
+		switch value {
+		case kAsioResetRequest,
+			kAsioEngineVersion,
+			kAsioResyncRequest,
+			kAsioLatenciesChanged,
+			kAsioBufferSizeChange,
+			kAsioSupportsInputMonitor,
+			kAsioSupportsTimeInfo,
+			kAsioSupportsTimeCode,
+			kAsioOverload:
+			return 1
+		}
+		return 0
+	case kAsioBufferSizeChange:
+		logger.Info("buffer size change requested, resetting", "buffer_size", value)
+		dev.publish(BufferSizeChange{Size: int(value)})
+		dev.requestReset(resetRequest{bufferSize: int(value)})
+		return 1
+	case kAsioResetRequest:
+		logger.Info("reset requested")
+		dev.publish(ResetRequested{})
+		dev.requestReset(resetRequest{reinit: true})
+		return 1
+	case kAsioResyncRequest:
+		logger.Info("resync requested")
+		dev.publish(ResyncRequested{})
+		dev.requestReset(resetRequest{})
+		return 1
+	case kAsioLatenciesChanged:
+		in, out, err := drv.GetLatencies()
+		if err != nil {
+			logger.Warn("latencies changed but cannot be fetched", "err", err)
+			return 1
+		}
+		logger.Info("latencies changed", "input_latency", in, "output_latency", out)
+		dev.publish(LatenciesChanged{In: in, Out: out})
+		return 1
+	case kAsioEngineVersion:
+		return 2
+	case kAsioSupportsTimeInfo, kAsioSupportsTimeCode:
+		return 1
+	case kAsioOverload:
+		logger.Warn("overload")
+		dev.publish(Overload{})
+		return 1
 	}
+	return 0
 }
 
-func (dev *Device) Reset() error {
-	if drv, err := dev.getDriver(); err != nil {
-		return err
-	} else {
-		if err = drv.Stop(); err != nil {
-			return err
+// channelInfo queries a channel, assuming ASIOSTInt32LSB when the driver cannot tell.
+func channelInfo(drv Driver, logger *slog.Logger, channel int, isInput bool) ChannelInfo {
+	cinfo, err := drv.GetChannelInfo(channel, isInput)
+	if err != nil {
+		logger.Warn("channel info unavailable, assuming Int32LSB",
+			"channel", channel, "input", isInput, "err", err)
+		return ChannelInfo{
+			Channel:    channel,
+			IsInput:    isInput,
+			SampleType: ASIOSTInt32LSB,
 		}
-		if err = drv.DisposeBuffers(); err != nil {
-			return err
+	}
+	logger.Debug("channel info", "channel", channel, "input", isInput, "active", cinfo.IsActive,
+		"group", cinfo.ChannelGroup, "type", cinfo.SampleType, "name", cinfo.Name)
+	return *cinfo
+}
+
+// resolveBufferSize returns the supported buffer size closest to requested.
+//
+// Supported sizes lie within [minSize, maxSize], stepping by granularity from
+// minSize; a granularity of -1 allows powers of two only and 0 only the preferred size.
+func resolveBufferSize(minSize, maxSize, preferredSize, granularity, requested int) int {
+	if requested <= 0 {
+		return preferredSize
+	}
+	size := min(max(requested, minSize), maxSize)
+
+	switch {
+	case granularity == -1:
+		best := 0
+		for p := 1; p <= maxSize; p <<= 1 {
+			if p < minSize {
+				continue
+			}
+			// ties go to the larger size, which is the safer choice
+			if best == 0 || abs(p-size) <= abs(best-size) {
+				best = p
+			}
 		}
-		if err = dev.Open(); err != nil {
-			return err
+		if best == 0 {
+			return preferredSize
+		}
+		return best
+	case granularity > 0:
+		steps := int(math.Round(float64(size-minSize) / float64(granularity)))
+		size = minSize + steps*granularity
+		for size > maxSize {
+			size -= granularity
+		}
+		return size
+	}
+	return preferredSize
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// BufferSize returns the buffer size in samples chosen by Open, or by the last reset.
+func (dev *Device) BufferSize() int {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.bufferSize
+}
+
+// selectChannels picks channels by number or by name, in the requested order.
+func selectChannels(all []ChannelInfo, numbers []int, names []string) ([]ChannelInfo, error) {
+	if numbers != nil && names != nil {
+		return nil, fmt.Errorf("channels selected both by number and by name")
+	}
+	if numbers == nil && names == nil {
+		return slices.Clone(all), nil
+	}
+
+	selected := make([]ChannelInfo, 0, len(numbers)+len(names))
+	seen := make(map[int]bool)
+	add := func(cinfo ChannelInfo) error {
+		if seen[cinfo.Channel] {
+			return fmt.Errorf("channel %d (%s) selected twice", cinfo.Channel, cinfo.Name)
+		}
+		seen[cinfo.Channel] = true
+		selected = append(selected, cinfo)
+		return nil
+	}
+
+	for _, n := range numbers {
+		if n < 0 || n >= len(all) {
+			return nil, fmt.Errorf("channel %d does not exist", n)
+		}
+		if err := add(all[n]); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(cinfo ChannelInfo) bool { return cinfo.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("channel %q does not exist", name)
 		}
-		if err = drv.Start(); err != nil {
+		if err := add(all[i]); err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
+
+// InputChannels returns the input channels buffers were created for by Open, in handler order.
+// ChannelInfo.Channel maps each handler channel back to the driver's channel number.
+func (dev *Device) InputChannels() []ChannelInfo {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.channels == nil {
+		return nil
+	}
+	return slices.Clone(dev.channels[:dev.numInputs])
+}
+
+// OutputChannels returns the output channels buffers were created for by Open, in handler order.
+func (dev *Device) OutputChannels() []ChannelInfo {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	if dev.channels == nil {
+		return nil
+	}
+	return slices.Clone(dev.channels[dev.numInputs:])
+}
+
+func (dev *Device) Close() error {
+	dev.lock()
+	defer dev.mu.Unlock()
+	return dev.close()
+}
+
+// close stops the driver and disposes its buffers, if any. dev.mu must be held.
+func (dev *Device) close() error {
+	if dev.state == StateRunning {
+		if err := dev.stop(); err != nil {
 			return err
 		}
+	}
+	if dev.state != StatePrepared {
 		return nil
 	}
+	if err := dev.unlocked(dev.drv.DisposeBuffers); err != nil {
+		return dev.driverError("DisposeBuffers", err)
+	}
+	dev.channels, dev.numInputs, dev.bufferSize = nil, 0, 0
+	dev.state = StateInitialized
+	return nil
+}
+
+// Reset stops the driver, recreates the buffers with the options of the last
+// Open and restarts processing with the same handler if it was running.
+// Resets requested by the driver are performed by the device itself; see ResetDebounce.
+func (dev *Device) Reset() error {
+	dev.lock()
+	defer dev.mu.Unlock()
+
+	if err := dev.require("Reset", StatePrepared, StateRunning); err != nil {
+		return err
+	}
+	return dev.resetLocked(resetRequest{})
+}
+
+// channelCodec returns the codec of a channel's sample type, recording
+// channels without one so that StartFloat can refuse to run.
+func (dev *Device) channelCodec(t SampleType) Codec {
+	c, err := NewCodec(t)
+	if err != nil {
+		if dev.codecErr == nil {
+			dev.codecErr = err
+		}
+		c, _ = NewCodec(ASIOSTInt32LSB)
+	}
+	return c
 }
 
+// rawBytes views n bytes of a driver buffer.
+func rawBytes(buffer *int32, n int) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(buffer)), n)
+}
+
+// Start starts processing with a handler receiving the driver's buffers as []int32, without conversion.
+// A channel whose sample type is not 4 bytes wide is passed as the int32 words
+// covering its buffer; see InputChannels and OutputChannels for the sample types.
 func (dev *Device) Start(handler func([][]int32, [][]int32)) error {
-	if drv, err := dev.getDriver(); err != nil {
+	if handler == nil {
+		return dev.StartWithTime(nil)
+	}
+	return dev.StartWithTime(func(in, out [][]int32, _ TimeInfo) { handler(in, out) })
+}
+
+// StartWithTime is like Start, with a handler also receiving the timing of each buffer.
+// For drivers not supporting bufferSwitchTimeInfo, only the sample position and
+// system time are filled in, from GetSamplePosition.
+func (dev *Device) StartWithTime(handler func(in, out [][]int32, timeInfo TimeInfo)) error {
+	dev.lock()
+	defer dev.mu.Unlock()
+
+	if err := dev.require("Start", StatePrepared); err != nil {
+		return err
+	}
+	if handler != nil {
+		dev.io_handler = handler
+		dev.float_handler = nil
+		dev.dsd_handler = nil
+	}
+	return dev.start()
+}
+
+// StartFloat starts processing with a handler receiving normalized samples in [-1, 1].
+// Samples are converted from and to each channel's sample type; output samples
+// outside of [-1, 1] are clipped for integer sample types. Output buffers are
+// zeroed before each call.
+func (dev *Device) StartFloat(handler func(in, out [][]float32)) error {
+	if handler == nil {
+		return dev.StartFloatWithTime(nil)
+	}
+	return dev.StartFloatWithTime(func(in, out [][]float32, _ TimeInfo) { handler(in, out) })
+}
+
+// StartFloatWithTime is like StartFloat, with a handler also receiving the timing of each buffer.
+func (dev *Device) StartFloatWithTime(handler func(in, out [][]float32, timeInfo TimeInfo)) error {
+	dev.lock()
+	defer dev.mu.Unlock()
+
+	if err := dev.require("StartFloat", StatePrepared); err != nil {
 		return err
-	} else {
-		if handler != nil {
-			dev.io_handler = handler
+	}
+	if dev.codecErr != nil {
+		return dev.codecErr
+	}
+	if handler != nil {
+		dev.float_handler = handler
+		dev.io_handler = nil
+		dev.dsd_handler = nil
+	}
+	return dev.start()
+}
+
+func (dev *Device) start() error {
+	if dev.float_handler != nil && dev.codecErr != nil {
+		return dev.codecErr
+	}
+	if dev.dsd_handler != nil {
+		if err := dev.dsdChannelsErr(); err != nil {
+			return err
 		}
-		return drv.Start()
 	}
+	if err := dev.drv.Start(); err != nil {
+		return dev.driverError("Start", err)
+	}
+	dev.state = StateRunning
+	return nil
 }
+
 func (dev *Device) Stop() error {
-	if drv, err := dev.getDriver(); err != nil {
+	dev.lock()
+	defer dev.mu.Unlock()
+	return dev.stop()
+}
+
+func (dev *Device) stop() error {
+	if err := dev.require("Stop", StateRunning); err != nil {
 		return err
-	} else {
-		return drv.Stop()
 	}
+	if err := dev.unlocked(dev.drv.Stop); err != nil {
+		return dev.driverError("Stop", err)
+	}
+	dev.state = StatePrepared
+	return nil
 }
@@ -0,0 +1,341 @@
+package asio
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceStartFloat(t *testing.T) {
+	in16, _ := NewCodec(ASIOSTInt16LSB)
+	out24, _ := NewCodec(ASIOSTInt24LSB)
+	outF32, _ := NewCodec(ASIOSTFloat32MSB)
+
+	var got [][]float64
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 2,
+		PreferredSize:  64,
+		InputTypes:     []SampleType{ASIOSTInt16LSB},
+		OutputTypes:    []SampleType{ASIOSTInt24LSB, ASIOSTFloat32MSB},
+		OnInput: func(index int, in [][]byte) {
+			samples := make([]int32, 64)
+			for i := range samples {
+				samples[i] = int32(i*1024 - 32768)
+			}
+			in16.EncodeInt32(in[0], samples)
+		},
+		OnOutput: func(index int, out [][]byte) {
+			got = [][]float64{make([]float64, 64), make([]float64, 64)}
+			out24.DecodeFloat64(got[0], out[0])
+			outF32.DecodeFloat64(got[1], out[1])
+		},
+	})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	if err := device.StartFloat(func(in, out [][]float32) {
+		for i, x := range in[0] {
+			out[0][i] = 2 * x // clipped for the integer channel
+			out[1][i] = 2 * x
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 64 {
+		x := 2 * float64(i*1024-32768) / 32768
+		if want := math.Max(-1, math.Min(x, 1-1.0/(1<<23))); math.Abs(got[0][i]-want) > 1e-6 {
+			t.Errorf("int24 out[%d] = %v, want %v", i, got[0][i], want)
+		}
+		if math.Abs(got[1][i]-x) > 1e-6 {
+			t.Errorf("float32 out[%d] = %v, want %v", i, got[1][i], x)
+		}
+	}
+}
+
+func TestDeviceStartFloatDSD(t *testing.T) {
+	sim := NewSimDriver(SimConfig{SampleType: ASIOSTDSDInt8MSB1})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	if err := device.StartFloat(func(in, out [][]float32) {}); err == nil {
+		t.Error("StartFloat on DSD channels succeeded")
+	}
+}
+
+func TestDeviceChannelInfo(t *testing.T) {
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  2,
+		OutputChannels: 1,
+		PreferredSize:  64,
+		InputTypes:     []SampleType{ASIOSTInt16LSB, ASIOSTInt24LSB},
+		OutputTypes:    []SampleType{ASIOSTFloat64LSB},
+	})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	inputs, outputs := device.InputChannels(), device.OutputChannels()
+	if len(inputs) != 2 || len(outputs) != 1 {
+		t.Fatalf("got %d inputs and %d outputs, want 2 and 1", len(inputs), len(outputs))
+	}
+	if inputs[0].SampleType != ASIOSTInt16LSB || inputs[1].SampleType != ASIOSTInt24LSB || !inputs[1].IsActive {
+		t.Errorf("inputs: %+v", inputs)
+	}
+	if outputs[0].SampleType != ASIOSTFloat64LSB || outputs[0].IsInput || outputs[0].Name != "Out 1" {
+		t.Errorf("outputs: %+v", outputs)
+	}
+
+	var lengths []int
+	if err := device.Start(func(in, out [][]int32) {
+		lengths = []int{len(in[0]), len(in[1]), len(out[0])}
+		for i := range out[0] {
+			out[0][i] = -1 // must stay within the buffer
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(2)
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 64 samples of 2, 3 and 8 bytes
+	if want := []int{32, 48, 128}; !slices.Equal(lengths, want) {
+		t.Errorf("int32 buffer lengths %v, want %v", lengths, want)
+	}
+}
+
+func TestDeviceOpenWithChannels(t *testing.T) {
+	var outputs [][]byte
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  4,
+		OutputChannels: 4,
+		PreferredSize:  64,
+		OnInput: func(index int, in [][]byte) {
+			for ch, buf := range in {
+				for i := 0; i < len(buf); i += 4 {
+					buf[i] = byte(ch + 1) // little endian int32 ch+1
+				}
+			}
+		},
+		OnOutput: func(index int, out [][]byte) {
+			outputs = out
+		},
+	})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	for _, opts := range []OpenOptions{
+		{InputChannels: []int{4}},
+		{InputChannels: []int{1, 1}},
+		{OutputChannelNames: []string{"Out 9"}},
+		{InputChannels: []int{0}, InputChannelNames: []string{"In 1"}},
+		{InputChannels: []int{}, OutputChannels: []int{}},
+	} {
+		if err := device.OpenWith(opts); err == nil {
+			device.Close()
+			t.Errorf("OpenWith(%+v) succeeded", opts)
+		}
+	}
+
+	if err := device.OpenWith(OpenOptions{
+		InputChannels:      []int{2, 0},
+		OutputChannelNames: []string{"Out 4"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	inputs := device.InputChannels()
+	if len(inputs) != 2 || inputs[0].Channel != 2 || inputs[1].Channel != 0 {
+		t.Errorf("inputs: %+v", inputs)
+	}
+	if out := device.OutputChannels(); len(out) != 1 || out[0].Channel != 3 {
+		t.Errorf("outputs: %+v", out)
+	}
+	if info, _ := sim.GetChannelInfo(1, true); info.IsActive {
+		t.Error("unselected channel 1 is active")
+	}
+
+	if err := device.Start(func(in, out [][]int32) {
+		if len(in) != 2 || len(out) != 1 {
+			t.Fatalf("got %d inputs and %d outputs, want 2 and 1", len(in), len(out))
+		}
+		for i := range out[0] {
+			out[0][i] = in[0][i]*10 + in[1][i]
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if outputs[0] != nil || outputs[3] == nil {
+		t.Fatalf("output buffers created for the wrong channels")
+	}
+	if outputs[3][0] != 31 {
+		t.Errorf("output sample %d, want 31 (hardware inputs 3 and 1)", outputs[3][0])
+	}
+}
+
+func TestResolveBufferSize(t *testing.T) {
+	for _, tc := range []struct {
+		min, max, preferred, granularity int
+		requested, want                  int
+	}{
+		{64, 2048, 256, -1, 0, 256},
+		{64, 2048, 256, -1, 100, 128},
+		{64, 2048, 256, -1, 96, 128}, // tie
+		{64, 2048, 256, -1, 90, 64},
+		{64, 2048, 256, -1, 1, 64},
+		{64, 2048, 256, -1, 100000, 2048},
+		{48, 1000, 256, -1, 40, 64}, // no power of two at min
+		{32, 512, 128, 32, 100, 96},
+		{32, 512, 128, 32, 112, 128},
+		{32, 500, 128, 32, 499, 480},
+		{32, 512, 128, 32, 8, 32},
+		{256, 256, 256, 0, 64, 256},
+		{64, 2048, 512, 0, 1024, 512},
+	} {
+		if got := resolveBufferSize(tc.min, tc.max, tc.preferred, tc.granularity, tc.requested); got != tc.want {
+			t.Errorf("resolveBufferSize(%d, %d, %d, %d, %d) = %d, want %d",
+				tc.min, tc.max, tc.preferred, tc.granularity, tc.requested, got, tc.want)
+		}
+	}
+}
+
+func TestDeviceOpenWithLatency(t *testing.T) {
+	sim := NewSimDriver(SimConfig{SampleRates: []float64{48000}})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	// 5ms at 48kHz is 240 samples
+	if err := device.OpenWith(OpenOptions{Latency: 5 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if size := device.BufferSize(); size != 256 {
+		t.Errorf("buffer size %d, want 256", size)
+	}
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := device.OpenWith(OpenOptions{BufferSize: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+	if size := device.BufferSize(); size != 1024 {
+		t.Errorf("buffer size %d, want 1024", size)
+	}
+}
+
+func TestDeviceLogger(t *testing.T) {
+	var logs bytes.Buffer
+	device := Device{
+		Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	if err := device.LoadDriver(NewSimDriver(SimConfig{Name: "Logged"})); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	for _, want := range []string{
+		`msg="buffers created" driver=Logged inputs=2 outputs=2 buffer_size=256`,
+		`msg="channel info" driver=Logged channel=1 input=false active=false group=0 type=Int32LSB name="Out 2"`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log lacks %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestDeviceClockSources(t *testing.T) {
+	sim := NewSimDriver(SimConfig{ClockSources: []string{"Internal", "Word Clock", "S/PDIF"}})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	if err := device.SetClockSource(3); err == nil {
+		t.Error("SetClockSource(3) succeeded")
+	}
+	if err := device.SetClockSource(1); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := device.GetClockSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 3 {
+		t.Fatalf("got %d clock sources, want 3", len(sources))
+	}
+	for i, source := range sources {
+		if source.Index != i || source.IsCurrentSource != (i == 1) || source.AssociatedChannel != -1 {
+			t.Errorf("clock source %d: %+v", i, source)
+		}
+	}
+	if sources[1].Name != "Word Clock" {
+		t.Errorf("clock source 1 named %q", sources[1].Name)
+	}
+
+	// the selection survives a reset reinitializing the driver
+	sim.SetClockSource(0)
+	device.mu.Lock()
+	err = device.resetLocked(resetRequest{reinit: true})
+	device.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sources, _ := device.GetClockSources(); !sources[1].IsCurrentSource {
+		t.Error("clock source not restored after reset")
+	}
+}
@@ -0,0 +1,53 @@
+package asio
+
+import "unsafe"
+
+// Driver is the host-facing surface of an ASIO driver.
+//
+// *IASIO satisfies it by calling into the COM object; other implementations
+// (such as software drivers) let Device and Session run without COM.
+type Driver interface {
+	// virtual ASIOBool init(void *sysHandle) = 0;
+	Init(sysHandle uintptr) bool
+	// virtual void getDriverName(char *name) = 0;
+	GetDriverName() string
+	// virtual long getDriverVersion() = 0;
+	GetDriverVersion() int32
+	// virtual void getErrorMessage(char *string) = 0;
+	GetErrorMessage() string
+
+	// virtual ASIOError start() = 0;
+	Start() error
+	// virtual ASIOError stop() = 0;
+	Stop() error
+	// virtual ASIOError getChannels(long *numInputChannels, long *numOutputChannels) = 0;
+	GetChannels() (numInputChannels, numOutputChannels int, err error)
+	// virtual ASIOError getLatencies(long *inputLatency, long *outputLatency) = 0;
+	GetLatencies() (inputLatency, outputLatency int, err error)
+	// virtual ASIOError getBufferSize(long *minSize, long *maxSize, long *preferredSize, long *granularity) = 0;
+	GetBufferSize() (minSize, maxSize, preferredSize, granularity int, err error)
+	// virtual ASIOError canSampleRate(ASIOSampleRate sampleRate) = 0;
+	CanSampleRate(sampleRate float64) error
+	// virtual ASIOError getSampleRate(ASIOSampleRate *sampleRate) = 0;
+	GetSampleRate() (sampleRate float64, err error)
+	// virtual ASIOError setSampleRate(ASIOSampleRate sampleRate) = 0;
+	SetSampleRate(sampleRate float64) error
+	// virtual ASIOError getClockSources(ASIOClockSource *clocks, long *numSources) = 0;
+	GetClockSources() ([]ClockSource, error)
+	// virtual ASIOError setClockSource(long reference) = 0;
+	SetClockSource(index int) error
+	// virtual ASIOError getSamplePosition(ASIOSamples *sPos, ASIOTimeStamp *tStamp) = 0;
+	GetSamplePosition() (samplePosition, timeStamp int64, err error)
+	// virtual ASIOError getChannelInfo(ASIOChannelInfo *info) = 0;
+	GetChannelInfo(channel int, isInput bool) (*ChannelInfo, error)
+	// virtual ASIOError createBuffers(ASIOBufferInfo *bufferInfos, long numChannels, long bufferSize, ASIOCallbacks *callbacks) = 0;
+	CreateBuffers(bufferDescriptors []BufferInfo, bufferSize int, callbacks Callbacks) error
+	// virtual ASIOError disposeBuffers() = 0;
+	DisposeBuffers() error
+	// virtual ASIOError controlPanel() = 0;
+	ControlPanel() error
+	// virtual ASIOError future(long selector,void *opt) = 0;
+	Future(selector FutureSelector, opt unsafe.Pointer) error
+	// virtual ASIOError outputReady() = 0;
+	OutputReady() bool
+}
@@ -1,117 +1,112 @@
 package asio
 
 import (
+	"errors"
 	"fmt"
-	"syscall"
-	"unsafe"
+	"log/slog"
+	"sync"
 )
 
+type GUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
 type ASIODriver struct {
 	Name  string
-	CLSID string
-	GUID  *GUID
-	ASIO  *IASIO
+	CLSID string // empty for software drivers
+	GUID  *GUID  // nil for software drivers
+	ASIO  Driver
+
+	// Factory of software drivers; nil for COM drivers registered on the system.
+	open func() (Driver, error)
 }
 
 func (drv *ASIODriver) Open() (err error) {
-	disp, err := CreateInstance(drv.GUID, drv.GUID)
-	if err != nil {
-		return
+	if drv.open == nil {
+		return drv.openCOM()
 	}
-	drv.ASIO = (*IASIO)(unsafe.Pointer(disp))
 
-	//drv.ASIO.AsIUnknown().AddRef()
+	asio, err := drv.open()
+	if err != nil {
+		return fmt.Errorf("%s: open: %w", drv.Name, err)
+	}
+	drv.ASIO = asio
 
 	ok := drv.ASIO.Init(uintptr(0))
 	if !ok {
-		return fmt.Errorf("could not init asio driver")
+		return initError(drv.Name, drv.ASIO)
 	}
 
 	return
 }
 
-func (drv *ASIODriver) Close() {
-	drv.ASIO.AsIUnknown().Release()
-}
-
-func newDriver(key syscall.Handle, keynameUTF16 winUTF16string) (drv *ASIODriver, err error) {
-	var subkey syscall.Handle
-	err = syscall.RegOpenKeyEx(key, keynameUTF16.Addr(), 0, syscall.KEY_READ, &subkey)
-	if err != nil {
-		return nil, err
-	}
-	defer syscall.RegCloseKey(subkey)
+// ErrInitFailed is returned when a driver refuses to initialize, typically
+// because its hardware is missing or in use by another application.
+var ErrInitFailed = errors.New("could not init asio driver")
 
-	clsidName, err := syscall.UTF16PtrFromString("clsid")
-	if err != nil {
-		return nil, err
+// initError describes a failed Init, with the driver's message if it has one.
+func initError(name string, drv Driver) error {
+	if msg := drv.GetErrorMessage(); msg != "" {
+		return fmt.Errorf("%s: Init: %w: %s", name, ErrInitFailed, msg)
 	}
+	return fmt.Errorf("%s: Init: %w", name, ErrInitFailed)
+}
 
-	// Get CLSID of driver impl:
-	clsidUTF16, datatype, datasize := make([]uint16, 128), uint32(syscall.REG_SZ), uint32(256)
-	err = syscall.RegQueryValueEx(subkey, clsidName, nil, &datatype, (*byte)(unsafe.Pointer(&clsidUTF16[0])), &datasize)
-	if err != nil {
-		return nil, err
+func (drv *ASIODriver) Close() {
+	if drv.open == nil {
+		drv.closeCOM()
 	}
+	drv.ASIO = nil
+}
 
-	// Convert the subkey name from UTF-16 to a string:
-	keyname := keynameUTF16.String()
-	drv = &ASIODriver{
-		Name:  keyname,
-		CLSID: syscall.UTF16ToString(clsidUTF16),
-	}
+var (
+	softwareDriversMu sync.Mutex
+	softwareDrivers   = map[string]func() (Driver, error){}
+)
 
-	drv.GUID, err = CLSIDFromStringUTF16(&clsidUTF16[0])
-	if err != nil {
-		return nil, err
+// RegisterDriver makes a software driver available to ListDrivers and Device.Load
+// on every platform. open is called each time the driver is opened and must
+// return a fresh, uninitialized instance.
+//
+// Drivers registered on the system take precedence over software drivers of the same name.
+func RegisterDriver(name string, open func() (Driver, error)) {
+	softwareDriversMu.Lock()
+	defer softwareDriversMu.Unlock()
+
+	if open == nil {
+		delete(softwareDrivers, name)
+		return
 	}
-
-	return drv, nil
+	softwareDrivers[name] = open
 }
 
-// Enumerate list of ASIO drivers registered on the system
+// Enumerate list of ASIO drivers: the ones registered on the system
+// (Windows only) followed by software drivers added with RegisterDriver.
 func ListDrivers() (drivers map[string]*ASIODriver, err error) {
-	var key syscall.Handle
-	key, err = RegOpenKey(syscall.HKEY_LOCAL_MACHINE, "Software\\ASIO", syscall.KEY_ENUMERATE_SUB_KEYS)
-	if err != nil {
-		return
-	}
-	defer syscall.RegCloseKey(key)
+	return listDrivers(discardLogger)
+}
 
+func listDrivers(logger *slog.Logger) (drivers map[string]*ASIODriver, err error) {
 	drivers = make(map[string]*ASIODriver)
 
-	// Enumerate subkeys:
-	index := uint32(0)
-	for err == nil {
-		keynameUTF16 := winUTF16string{
-			utf16:  make([]uint16, 128),
-			length: uint32(128),
-		}
-
-		// Get next subkey:
-		err = syscall.RegEnumKeyEx(key, index, keynameUTF16.Addr(), &keynameUTF16.length, nil, nil, nil, nil)
-		// Determine when to stop:
-		if err != nil {
-			if errno, ok := err.(syscall.Errno); ok {
-				// 259 is "No more data" error; aka end of enumeration.
-				if uintptr(errno) == uintptr(259) {
-					err = nil
-					break
-				}
-			}
-			fmt.Println(err)
-			return
-		}
+	if err = listSystemDrivers(drivers, logger); err != nil {
+		return nil, fmt.Errorf("list drivers: %w", err)
+	}
 
-		index++
+	softwareDriversMu.Lock()
+	defer softwareDriversMu.Unlock()
 
-		// Create an ASIODriver based on the key:
-		drv, err := newDriver(key, keynameUTF16)
-		if err != nil {
+	for name, open := range softwareDrivers {
+		if _, ok := drivers[name]; ok {
 			continue
 		}
-
-		drivers[drv.Name] = drv
+		drivers[name] = &ASIODriver{
+			Name: name,
+			open: open,
+		}
 	}
 
 	return drivers, nil
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package asio
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ASIO drivers are COM objects, which only exist on Windows.
+
+func (drv *ASIODriver) openCOM() error {
+	return fmt.Errorf("asio driver %s: COM drivers are only available on Windows", drv.Name)
+}
+
+func (drv *ASIODriver) closeCOM() {}
+
+func coInitialize() {}
+
+func coUninitialize() {}
+
+func listSystemDrivers(drivers map[string]*ASIODriver, logger *slog.Logger) error {
+	return nil
+}
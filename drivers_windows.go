@@ -0,0 +1,128 @@
+//go:build windows
+
+package asio
+
+import (
+	"fmt"
+	"log/slog"
+	"syscall"
+	"unsafe"
+)
+
+func (drv *ASIODriver) openCOM() (err error) {
+	disp, err := CreateInstance(drv.GUID, drv.GUID)
+	if err != nil {
+		return fmt.Errorf("%s: CoCreateInstance: %w", drv.Name, err)
+	}
+	asio := (*IASIO)(unsafe.Pointer(disp))
+	drv.ASIO = asio
+
+	//asio.AsIUnknown().AddRef()
+
+	ok := asio.Init(uintptr(0))
+	if !ok {
+		return initError(drv.Name, asio)
+	}
+
+	return
+}
+
+func (drv *ASIODriver) closeCOM() {
+	if asio, ok := drv.ASIO.(*IASIO); ok {
+		releaseCallbackSlot(asio)
+		asio.AsIUnknown().Release()
+	}
+}
+
+func coInitialize() {
+	CoInitialize(0)
+}
+
+func coUninitialize() {
+	CoUninitialize()
+}
+
+func newDriver(key syscall.Handle, keynameUTF16 winUTF16string) (drv *ASIODriver, err error) {
+	var subkey syscall.Handle
+	err = syscall.RegOpenKeyEx(key, keynameUTF16.Addr(), 0, syscall.KEY_READ, &subkey)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.RegCloseKey(subkey)
+
+	clsidName, err := syscall.UTF16PtrFromString("clsid")
+	if err != nil {
+		return nil, err
+	}
+
+	// Get CLSID of driver impl:
+	clsidUTF16, datatype, datasize := make([]uint16, 128), uint32(syscall.REG_SZ), uint32(256)
+	err = syscall.RegQueryValueEx(subkey, clsidName, nil, &datatype, (*byte)(unsafe.Pointer(&clsidUTF16[0])), &datasize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the subkey name from UTF-16 to a string:
+	keyname := keynameUTF16.String()
+	drv = &ASIODriver{
+		Name:  keyname,
+		CLSID: syscall.UTF16ToString(clsidUTF16),
+	}
+
+	drv.GUID, err = CLSIDFromStringUTF16(&clsidUTF16[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return drv, nil
+}
+
+// Enumerate ASIO drivers registered on the system into drivers.
+func listSystemDrivers(drivers map[string]*ASIODriver, logger *slog.Logger) (err error) {
+	var key syscall.Handle
+	key, err = RegOpenKey(syscall.HKEY_LOCAL_MACHINE, "Software\\ASIO", syscall.KEY_ENUMERATE_SUB_KEYS)
+	if err != nil {
+		if err == syscall.ERROR_FILE_NOT_FOUND {
+			// No ASIO driver has ever been installed.
+			return nil
+		}
+		return
+	}
+	defer syscall.RegCloseKey(key)
+
+	// Enumerate subkeys:
+	index := uint32(0)
+	for err == nil {
+		keynameUTF16 := winUTF16string{
+			utf16:  make([]uint16, 128),
+			length: uint32(128),
+		}
+
+		// Get next subkey:
+		err = syscall.RegEnumKeyEx(key, index, keynameUTF16.Addr(), &keynameUTF16.length, nil, nil, nil, nil)
+		// Determine when to stop:
+		if err != nil {
+			if errno, ok := err.(syscall.Errno); ok {
+				// 259 is "No more data" error; aka end of enumeration.
+				if uintptr(errno) == uintptr(259) {
+					err = nil
+					break
+				}
+			}
+			return
+		}
+
+		index++
+
+		// Create an ASIODriver based on the key:
+		drv, err := newDriver(key, keynameUTF16)
+		if err != nil {
+			logger.Warn("skipping registered driver", "key", keynameUTF16.String(), "err", err)
+			continue
+		}
+
+		drivers[drv.Name] = drv
+	}
+
+	return nil
+}
@@ -0,0 +1,462 @@
+package asio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// Sample rates of DSD:
+const (
+	DSD64SampleRate  = 64 * 44100
+	DSD128SampleRate = 128 * 44100
+	DSD256SampleRate = 256 * 44100
+)
+
+// dsdReferenceLevel is the modulation index of a full scale PCM signal, 50% as for SACD.
+// Higher modulation overloads the modulator.
+const dsdReferenceLevel = 0.5
+
+// DSDBuffer is a view of a buffer of 1 bit DSD samples in one of the DSD sample types.
+//
+// ASIOSTDSDInt8MSB1 and ASIOSTDSDInt8LSB1 pack 8 samples per byte, the first
+// in the most or least significant bit. ASIOSTDSDInt8NER8 holds one sample per
+// byte, written as 0 or 1; any nonzero byte reads as 1.
+type DSDBuffer struct {
+	typ  SampleType
+	data []byte
+}
+
+// NewDSDBuffer views buf as samples of a DSD sample type.
+func NewDSDBuffer(t SampleType, buf []byte) (DSDBuffer, error) {
+	switch t {
+	case ASIOSTDSDInt8LSB1, ASIOSTDSDInt8MSB1, ASIOSTDSDInt8NER8:
+		return DSDBuffer{typ: t, data: buf}, nil
+	}
+	return DSDBuffer{}, fmt.Errorf("sample type %v is not DSD", t)
+}
+
+// SampleType returns the sample type of the buffer.
+func (b DSDBuffer) SampleType() SampleType { return b.typ }
+
+// Bytes returns the underlying buffer.
+func (b DSDBuffer) Bytes() []byte { return b.data }
+
+// Len returns the number of DSD samples in the buffer.
+func (b DSDBuffer) Len() int {
+	if b.typ == ASIOSTDSDInt8NER8 {
+		return len(b.data)
+	}
+	return 8 * len(b.data)
+}
+
+// Bit returns sample i, true for a one.
+func (b DSDBuffer) Bit(i int) bool {
+	switch b.typ {
+	case ASIOSTDSDInt8NER8:
+		return b.data[i] != 0
+	case ASIOSTDSDInt8LSB1:
+		return b.data[i/8]>>(i%8)&1 != 0
+	}
+	return b.data[i/8]>>(7-i%8)&1 != 0
+}
+
+// SetBit sets sample i, true for a one.
+func (b DSDBuffer) SetBit(i int, v bool) {
+	if b.typ == ASIOSTDSDInt8NER8 {
+		b.data[i] = byte(bool_int32(v))
+		return
+	}
+	mask := byte(0x80) >> (i % 8)
+	if b.typ == ASIOSTDSDInt8LSB1 {
+		mask = 1 << (i % 8)
+	}
+	if v {
+		b.data[i/8] |= mask
+	} else {
+		b.data[i/8] &^= mask
+	}
+}
+
+// byteAt returns samples i to i+7 packed with the first one in the most significant bit.
+// i must be a multiple of 8.
+func (b DSDBuffer) byteAt(i int) byte {
+	switch b.typ {
+	case ASIOSTDSDInt8MSB1:
+		return b.data[i/8]
+	case ASIOSTDSDInt8LSB1:
+		return bits.Reverse8(b.data[i/8])
+	}
+	var v byte
+	for _, s := range b.data[i : i+8] {
+		v = v<<1 | byte(bool_int32(s != 0))
+	}
+	return v
+}
+
+// setByteAt is the inverse of byteAt.
+func (b DSDBuffer) setByteAt(i int, v byte) {
+	switch b.typ {
+	case ASIOSTDSDInt8MSB1:
+		b.data[i/8] = v
+	case ASIOSTDSDInt8LSB1:
+		b.data[i/8] = bits.Reverse8(v)
+	default:
+		for j := range 8 {
+			b.data[i+j] = v >> (7 - j) & 1
+		}
+	}
+}
+
+// Silence fills the buffer with the idle pattern of DSD, a signal averaging zero.
+func (b DSDBuffer) Silence() {
+	for i := 0; i+8 <= b.Len(); i += 8 {
+		b.setByteAt(i, 0x69)
+	}
+}
+
+// lowpass designs a linear phase FIR low-pass filter as a Blackman windowed sinc,
+// with a cutoff in cycles per sample and unity gain at DC.
+func lowpass(taps int, cutoff float64) []float64 {
+	h := make([]float64, taps)
+	var sum float64
+	for i := range h {
+		x := float64(i) - float64(taps-1)/2
+		w := 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(taps-1)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(taps-1))
+		s := 2 * cutoff
+		if x != 0 {
+			s = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		h[i] = s * w
+		sum += h[i]
+	}
+	for i := range h {
+		h[i] /= sum
+	}
+	return h
+}
+
+// tapsPerOutput is the length of the decimation filter in output samples.
+const tapsPerOutput = 32
+
+// decimationTables caches the lookup tables of DSDDecimator, shared by every
+// decimator with the same factor.
+var decimationTables struct {
+	sync.Mutex
+	tables map[int][][256]float64
+}
+
+// decimationTable returns the filter of a decimation factor as one table per
+// byte of history, holding the sum of 8 taps weighted by the bits of each byte value.
+func decimationTable(factor int) [][256]float64 {
+	decimationTables.Lock()
+	defer decimationTables.Unlock()
+	if t, ok := decimationTables.tables[factor]; ok {
+		return t
+	}
+
+	// cutoff at 40% of the output rate; the stop band starts just below its Nyquist frequency
+	h := lowpass(tapsPerOutput*factor, 0.4/float64(factor))
+	tables := make([][256]float64, len(h)/8)
+	for k := range tables {
+		for b := range 256 {
+			var sum float64
+			for j := range 8 {
+				// the last sample of a byte is the least significant bit
+				if b>>j&1 != 0 {
+					sum += h[8*k+j]
+				} else {
+					sum -= h[8*k+j]
+				}
+			}
+			tables[k][b] = sum / dsdReferenceLevel
+		}
+	}
+	if decimationTables.tables == nil {
+		decimationTables.tables = map[int][][256]float64{}
+	}
+	decimationTables.tables[factor] = tables
+	return tables
+}
+
+// DSDDecimator converts a channel of DSD to PCM, low-pass filtering and decimating it.
+// Its state carries over between calls, so a stream may be passed in buffers of any length.
+type DSDDecimator struct {
+	factor  int // DSD samples per PCM sample
+	tables  [][256]float64
+	history []byte // ring of the last len(tables) bytes
+	pos     int    // of the next byte in history
+	count   int    // bytes since the last PCM sample
+	cur     byte   // partial byte of NER8 samples
+	nbits   int
+}
+
+// NewDSDDecimator returns a decimator from dsdRate to pcmRate, such as DSD64SampleRate to 88200.
+// dsdRate must be a multiple of 8 times pcmRate.
+func NewDSDDecimator(dsdRate, pcmRate float64) (*DSDDecimator, error) {
+	factor := int(dsdRate / pcmRate)
+	if pcmRate <= 0 || float64(factor)*pcmRate != dsdRate || factor%8 != 0 {
+		return nil, fmt.Errorf("cannot decimate DSD at %v Hz to %v Hz", dsdRate, pcmRate)
+	}
+	tables := decimationTable(factor)
+	d := &DSDDecimator{
+		factor:  factor,
+		tables:  tables,
+		history: make([]byte, len(tables)),
+	}
+	d.Reset()
+	return d, nil
+}
+
+// Factor returns the number of DSD samples per PCM sample.
+func (d *DSDDecimator) Factor() int { return d.factor }
+
+// Reset clears the state of the decimator, as if it had received silence.
+func (d *DSDDecimator) Reset() {
+	for i := range d.history {
+		d.history[i] = 0x69
+	}
+	d.pos, d.count, d.cur, d.nbits = 0, 0, 0, 0
+}
+
+// Decimate filters the samples of src and appends the resulting normalized PCM
+// samples to dst, one per Factor DSD samples. A 50% modulated DSD signal
+// decodes to full scale.
+func (d *DSDDecimator) Decimate(dst []float64, src DSDBuffer) []float64 {
+	n := src.Len()
+	for i := 0; i < n; {
+		if d.nbits == 0 && n-i >= 8 && (i%8 == 0 || src.typ == ASIOSTDSDInt8NER8) {
+			dst = d.push(dst, src.byteAt(i))
+			i += 8
+			continue
+		}
+		d.cur = d.cur<<1 | byte(bool_int32(src.Bit(i)))
+		if d.nbits++; d.nbits == 8 {
+			dst = d.push(dst, d.cur)
+			d.cur, d.nbits = 0, 0
+		}
+		i++
+	}
+	return dst
+}
+
+func (d *DSDDecimator) push(dst []float64, b byte) []float64 {
+	d.history[d.pos] = b
+	if d.pos++; d.pos == len(d.history) {
+		d.pos = 0
+	}
+	if d.count++; d.count < d.factor/8 {
+		return dst
+	}
+	d.count = 0
+
+	// table k applies to the byte k places before the newest
+	var y float64
+	newest := d.pos - 1 + len(d.history)
+	for k := range d.tables {
+		y += d.tables[k][d.history[(newest-k)%len(d.history)]]
+	}
+	return append(dst, y)
+}
+
+// ntfDenominator holds the poles of the noise transfer function of DSDModulator.
+// The NTF has 5 zeros at DC and the poles of a 5th order Butterworth high-pass,
+// placed for a gain of 1.5 at the Nyquist frequency, which keeps the 1 bit loop stable.
+var ntfDenominator = [6]float64{1, -4.1922821511385049, 7.0857914157082842, -6.0296073835111041, 2.5812079389633493, -0.44444444401209576}
+
+// ntfNumerator is (1 - z⁻¹)⁵.
+var ntfNumerator = [6]float64{1, -5, 10, -10, 5, -1}
+
+// dsdModulatorLimit bounds the loop filter output; beyond it the modulator
+// is considered unstable and restarts.
+const dsdModulatorLimit = 16
+
+// DSDModulator converts a channel of PCM to DSD with a 5th order sigma-delta
+// modulator, upsampling the PCM first with a windowed sinc interpolator.
+// Its state carries over between calls.
+type DSDModulator struct {
+	factor  int       // DSD samples per PCM sample
+	phases  []float64 // interpolation filter, tapsPerInput per phase
+	input   []float64 // ring of the last tapsPerInput PCM samples
+	pos     int       // of the next sample in input
+	errs    [5]float64
+	filters [5]float64
+}
+
+// tapsPerInput is the length of the interpolation filter in input samples.
+const tapsPerInput = 64
+
+// NewDSDModulator returns a modulator from pcmRate to dsdRate, such as 44100 to DSD64SampleRate.
+// dsdRate must be a multiple of pcmRate; if they are equal no interpolation is done.
+func NewDSDModulator(pcmRate, dsdRate float64) (*DSDModulator, error) {
+	factor := int(dsdRate / pcmRate)
+	if pcmRate <= 0 || factor < 1 || float64(factor)*pcmRate != dsdRate {
+		return nil, fmt.Errorf("cannot modulate PCM at %v Hz to DSD at %v Hz", pcmRate, dsdRate)
+	}
+	m := &DSDModulator{factor: factor}
+	if factor > 1 {
+		// pass band up to 45% of the input rate, images rejected from 55%
+		h := lowpass(tapsPerInput*factor, 0.5/float64(factor))
+		m.phases = make([]float64, len(h))
+		for p := range factor {
+			for j := range tapsPerInput {
+				m.phases[p*tapsPerInput+j] = h[p+j*factor] * float64(factor)
+			}
+		}
+		m.input = make([]float64, tapsPerInput)
+	}
+	return m, nil
+}
+
+// Factor returns the number of DSD samples per PCM sample.
+func (m *DSDModulator) Factor() int { return m.factor }
+
+// Reset clears the state of the modulator.
+func (m *DSDModulator) Reset() {
+	clear(m.input)
+	m.pos = 0
+	m.errs = [5]float64{}
+	m.filters = [5]float64{}
+}
+
+// Modulate converts the normalized PCM samples of src to DSD in dst and
+// returns the number of PCM samples converted, each filling Factor samples of dst.
+// Full scale PCM is modulated to 50%, the reference level of SACD; louder
+// samples are clipped.
+func (m *DSDModulator) Modulate(dst DSDBuffer, src []float64) int {
+	n := min(len(src), dst.Len()/m.factor)
+	for i := range n {
+		x := min(max(src[i], -1), 1) * dsdReferenceLevel
+		if m.input == nil {
+			dst.SetBit(i, m.quantize(x))
+			continue
+		}
+		m.input[m.pos] = x
+		if m.pos++; m.pos == len(m.input) {
+			m.pos = 0
+		}
+		for p := range m.factor {
+			var y float64
+			taps := m.phases[p*tapsPerInput : (p+1)*tapsPerInput]
+			for j, h := range taps {
+				y += h * m.input[(m.pos-1-j+2*tapsPerInput)%tapsPerInput]
+			}
+			dst.SetBit(i*m.factor+p, m.quantize(y))
+		}
+	}
+	return n
+}
+
+// quantize runs one step of the modulator loop, in error feedback form:
+// the output is x + NTF·e, with e the quantization error.
+func (m *DSDModulator) quantize(x float64) bool {
+	// (NTF - 1) filters past errors; both NTF polynomials start with 1
+	var w float64
+	for k := range 5 {
+		w += (ntfNumerator[k+1]-ntfDenominator[k+1])*m.errs[k] - ntfDenominator[k+1]*m.filters[k]
+	}
+	v := x + w
+	if math.Abs(v) > dsdModulatorLimit || math.IsNaN(v) {
+		m.errs, m.filters = [5]float64{}, [5]float64{}
+		v = x
+	}
+	out := v >= 0
+	y := -1.0
+	if out {
+		y = 1
+	}
+	copy(m.errs[1:], m.errs[:4])
+	copy(m.filters[1:], m.filters[:4])
+	m.errs[0], m.filters[0] = y-v, w
+	return out
+}
+
+// DoP (DSD over PCM) markers, alternating between frames in the top byte of 24 bit samples.
+const (
+	dopMarker1 = 0x05
+	dopMarker2 = 0xFA
+)
+
+// ErrNotDoP is returned by DecodeDoP for samples without DoP markers.
+var ErrNotDoP = errors.New("not a DoP stream")
+
+// DoPEncoder packs DSD into DoP frames: 24 bit PCM samples at 1/16 of the DSD
+// rate, such as 176.4kHz for DSD64, holding 16 DSD samples below a marker byte.
+// Channels of a stream need an encoder each, started together so that their
+// markers match.
+type DoPEncoder struct {
+	frames int
+}
+
+// Encode packs the samples of src into dst as 24 bit integers, as exchanged by
+// Codec for ASIOSTInt24LSB; shift them left by 8 for 32 bit sample types.
+// It returns the number of PCM samples written; a trailing part of src shorter than 16 samples is ignored.
+func (e *DoPEncoder) Encode(dst []int32, src DSDBuffer) int {
+	n := min(len(dst), src.Len()/16)
+	for i := range n {
+		marker := uint32(dopMarker1)
+		if e.frames%2 != 0 {
+			marker = dopMarker2
+		}
+		e.frames++
+		// built in the top 24 bits, then sign extended
+		dst[i] = int32(marker<<24|uint32(src.byteAt(16*i))<<16|uint32(src.byteAt(16*i+8))<<8) >> 8
+	}
+	return n
+}
+
+// DecodeDoP unpacks the DSD samples of DoP frames in src, 24 bit integers as written by
+// DoPEncoder, into dst. It returns the number of PCM samples decoded, or ErrNotDoP
+// if a sample has no valid marker.
+func DecodeDoP(dst DSDBuffer, src []int32) (int, error) {
+	n := min(len(src), dst.Len()/16)
+	for i, v := range src[:n] {
+		if marker := byte(v >> 16); marker != dopMarker1 && marker != dopMarker2 {
+			return i, fmt.Errorf("sample %d: %w: marker %#02x", i, ErrNotDoP, marker)
+		}
+		dst.setByteAt(16*i, byte(v>>8))
+		dst.setByteAt(16*i+8, byte(v))
+	}
+	return n, nil
+}
+
+// StartDSD starts processing with a handler receiving the driver's buffers as DSD,
+// after switching the driver to DSD with SetIoFormat. Every channel must have a
+// DSD sample type. Buffers are passed without conversion, output buffers included.
+func (dev *Device) StartDSD(handler func(in, out []DSDBuffer)) error {
+	if handler == nil {
+		return dev.StartDSDWithTime(nil)
+	}
+	return dev.StartDSDWithTime(func(in, out []DSDBuffer, _ TimeInfo) { handler(in, out) })
+}
+
+// StartDSDWithTime is like StartDSD, with a handler also receiving the timing of each buffer.
+func (dev *Device) StartDSDWithTime(handler func(in, out []DSDBuffer, timeInfo TimeInfo)) error {
+	dev.lock()
+	defer dev.mu.Unlock()
+
+	if err := dev.require("StartDSD", StatePrepared); err != nil {
+		return err
+	}
+	if err := dev.dsdChannelsErr(); err != nil {
+		return err
+	}
+	if handler != nil {
+		dev.dsd_handler = handler
+		dev.io_handler = nil
+		dev.float_handler = nil
+	}
+	return dev.start()
+}
+
+// dsdChannelsErr tells why the open buffers cannot be passed to a DSD handler.
+func (dev *Device) dsdChannelsErr() error {
+	for _, c := range dev.channels {
+		if _, err := NewDSDBuffer(c.SampleType, nil); err != nil {
+			return fmt.Errorf("channel %q: %w", c.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package asio
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+var dsdSampleTypes = []SampleType{ASIOSTDSDInt8LSB1, ASIOSTDSDInt8MSB1, ASIOSTDSDInt8NER8}
+
+func TestDSDBuffer(t *testing.T) {
+	pattern := []bool{true, false, false, false, false, true, true, false, true}
+	for _, tc := range []struct {
+		st   SampleType
+		size int
+		want []byte
+	}{
+		{ASIOSTDSDInt8MSB1, 2, []byte{0x86, 0x80}},
+		{ASIOSTDSDInt8LSB1, 2, []byte{0x61, 0x01}},
+		{ASIOSTDSDInt8NER8, 9, []byte{1, 0, 0, 0, 0, 1, 1, 0, 1}},
+	} {
+		b, err := NewDSDBuffer(tc.st, make([]byte, tc.size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range pattern {
+			b.SetBit(i, v)
+		}
+		if !bytes.Equal(b.Bytes(), tc.want) {
+			t.Errorf("%v: % x, want % x", tc.st, b.Bytes(), tc.want)
+		}
+		for i, v := range pattern {
+			if b.Bit(i) != v {
+				t.Errorf("%v: sample %d is %v", tc.st, i, b.Bit(i))
+			}
+		}
+		b.SetBit(0, false)
+		if b.Bit(0) {
+			t.Errorf("%v: sample 0 not cleared", tc.st)
+		}
+	}
+
+	if _, err := NewDSDBuffer(ASIOSTInt32LSB, nil); err == nil {
+		t.Error("NewDSDBuffer accepted a PCM sample type")
+	}
+}
+
+// newDSDBuffer returns a buffer of n samples.
+func newDSDBuffer(st SampleType, n int) DSDBuffer {
+	if st != ASIOSTDSDInt8NER8 {
+		n /= 8
+	}
+	b, _ := NewDSDBuffer(st, make([]byte, n))
+	return b
+}
+
+// modulateSine returns seconds of a sine modulated to DSD.
+func modulateSine(t *testing.T, st SampleType, pcmRate, dsdRate, freq, amplitude, seconds float64) DSDBuffer {
+	t.Helper()
+	m, err := NewDSDModulator(pcmRate, dsdRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]float64, int(pcmRate*seconds))
+	for i := range pcm {
+		pcm[i] = amplitude * math.Sin(2*math.Pi*freq*float64(i)/pcmRate)
+	}
+	b := newDSDBuffer(st, len(pcm)*m.Factor())
+	if n := m.Modulate(b, pcm); n != len(pcm) {
+		t.Fatalf("modulated %d samples, want %d", n, len(pcm))
+	}
+	return b
+}
+
+// fitSine returns the amplitude of a sine of the given frequency fitted to x
+// by least squares, and the signal to noise ratio of x in dB.
+func fitSine(x []float64, freq, rate float64) (amplitude, snr float64) {
+	var ss, sc, cc, sx, cx float64
+	for i, v := range x {
+		s, c := math.Sincos(2 * math.Pi * freq * float64(i) / rate)
+		ss, sc, cc = ss+s*s, sc+s*c, cc+c*c
+		sx, cx = sx+s*v, cx+c*v
+	}
+	det := ss*cc - sc*sc
+	a, b := (sx*cc-cx*sc)/det, (cx*ss-sx*sc)/det
+	var signal, noise float64
+	for i, v := range x {
+		s, c := math.Sincos(2 * math.Pi * freq * float64(i) / rate)
+		fit := a*s + b*c
+		signal += fit * fit
+		noise += (v - fit) * (v - fit)
+	}
+	return math.Hypot(a, b), 10 * math.Log10(signal/noise)
+}
+
+func TestDSDRoundTrip(t *testing.T) {
+	const freq, amplitude = 1000, 0.5
+	for _, tc := range []struct {
+		pcmRate, dsdRate, outRate float64
+		snr                       float64 // minimum, in dB
+	}{
+		{44100, DSD64SampleRate, 88200, 60},
+		// the shaped noise of DSD64 rises steeply within the band of 176.4kHz
+		{88200, DSD64SampleRate, 176400, 30},
+		{44100, DSD128SampleRate, 176400, 60},
+		{176400, DSD256SampleRate, 88200, 100},
+	} {
+		dsd := modulateSine(t, ASIOSTDSDInt8MSB1, tc.pcmRate, tc.dsdRate, freq, amplitude, 0.05)
+
+		d, err := NewDSDDecimator(tc.dsdRate, tc.outRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pcm := d.Decimate(nil, dsd)
+		if want := dsd.Len() / d.Factor(); len(pcm) != want {
+			t.Fatalf("%v Hz: decimated to %d samples, want %d", tc.dsdRate, len(pcm), want)
+		}
+
+		// skip the delay of the filters
+		a, snr := fitSine(pcm[len(pcm)/2:], freq, tc.outRate)
+		if math.Abs(a-amplitude) > 1e-3 || snr < tc.snr {
+			t.Errorf("%v Hz → %v Hz → %v Hz: amplitude %.5f, SNR %.1f dB", tc.pcmRate, tc.dsdRate, tc.outRate, a, snr)
+		}
+	}
+}
+
+func TestDSDDecimator(t *testing.T) {
+	dsd := modulateSine(t, ASIOSTDSDInt8MSB1, 44100, DSD64SampleRate, 3000, 0.8, 0.01)
+
+	d, err := NewDSDDecimator(DSD64SampleRate, 88200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := d.Decimate(nil, dsd)
+
+	// same samples in the other sample types
+	for _, st := range dsdSampleTypes {
+		b := newDSDBuffer(st, dsd.Len())
+		for i := range dsd.Len() {
+			b.SetBit(i, dsd.Bit(i))
+		}
+		d.Reset()
+		if got := d.Decimate(nil, b); !slices.Equal(got, want) {
+			t.Errorf("%v: decimated differently than %v", st, ASIOSTDSDInt8MSB1)
+		}
+	}
+
+	// split into buffers of random length
+	r := rand.New(rand.NewSource(1))
+	ner8 := newDSDBuffer(ASIOSTDSDInt8NER8, dsd.Len())
+	for i := range dsd.Len() {
+		ner8.SetBit(i, dsd.Bit(i))
+	}
+	d.Reset()
+	var got []float64
+	for rest := ner8.Bytes(); len(rest) > 0; {
+		n := min(len(rest), 1+r.Intn(100))
+		b, _ := NewDSDBuffer(ASIOSTDSDInt8NER8, rest[:n])
+		got = d.Decimate(got, b)
+		rest = rest[n:]
+	}
+	if !slices.Equal(got, want) {
+		t.Error("decimated differently in pieces")
+	}
+
+	// the idle pattern is silent, full modulation twice full scale
+	silence, _ := NewDSDBuffer(ASIOSTDSDInt8MSB1, make([]byte, 4096))
+	silence.Silence()
+	d.Reset()
+	for i, v := range d.Decimate(nil, silence) {
+		if math.Abs(v) > 1e-9 {
+			t.Fatalf("silence decoded to %v at %d", v, i)
+		}
+	}
+	ones, _ := NewDSDBuffer(ASIOSTDSDInt8LSB1, bytes.Repeat([]byte{0xff}, 4096))
+	if pcm := d.Decimate(nil, ones); math.Abs(pcm[len(pcm)-1]-2) > 1e-9 {
+		t.Errorf("all ones decoded to %v, want 2", pcm[len(pcm)-1])
+	}
+
+	for _, rates := range [][2]float64{{DSD64SampleRate, 44100 * 3}, {DSD64SampleRate, DSD64SampleRate / 4}, {DSD64SampleRate, 0}} {
+		if _, err := NewDSDDecimator(rates[0], rates[1]); err == nil {
+			t.Errorf("decimator from %v Hz to %v Hz", rates[0], rates[1])
+		}
+	}
+	if _, err := NewDSDModulator(48000, DSD64SampleRate); err == nil {
+		t.Error("modulator from 48000 Hz to DSD64")
+	}
+}
+
+func TestDoP(t *testing.T) {
+	dsd := modulateSine(t, ASIOSTDSDInt8LSB1, 44100, DSD64SampleRate, 1000, 0.5, 0.001)
+
+	var e DoPEncoder
+	frames := make([]int32, dsd.Len()/16)
+	if n := e.Encode(frames[:3], dsd); n != 3 {
+		t.Fatalf("encoded %d frames, want 3", n)
+	}
+	rest, _ := NewDSDBuffer(dsd.SampleType(), dsd.Bytes()[6:])
+	e.Encode(frames[3:], rest)
+
+	c, _ := NewCodec(ASIOSTInt24LSB)
+	raw := make([]byte, len(frames)*c.Size())
+	c.EncodeInt32(raw, frames)
+	for i := range frames {
+		want := byte(0x05)
+		if i%2 != 0 {
+			want = 0xFA
+		}
+		if marker := raw[3*i+2]; marker != want {
+			t.Fatalf("frame %d: marker %#x, want %#x", i, marker, want)
+		}
+	}
+
+	decoded := newDSDBuffer(ASIOSTDSDInt8MSB1, dsd.Len())
+	if n, err := DecodeDoP(decoded, frames); n != len(frames) || err != nil {
+		t.Fatalf("DecodeDoP() = %d, %v", n, err)
+	}
+	for i := range dsd.Len() {
+		if decoded.Bit(i) != dsd.Bit(i) {
+			t.Fatalf("sample %d changed by DoP", i)
+		}
+	}
+
+	pcm := []int32{0x05_1234, 0x12_3456}
+	if n, err := DecodeDoP(decoded, pcm); n != 1 || !errors.Is(err, ErrNotDoP) {
+		t.Errorf("DecodeDoP of PCM = %d, %v", n, err)
+	}
+}
+
+func TestDeviceStartDSD(t *testing.T) {
+	const bufferSize = 256
+	dsd := modulateSine(t, ASIOSTDSDInt8MSB1, 44100, DSD64SampleRate, 1000, 0.5, 0.02)
+
+	var played []byte
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 1,
+		PreferredSize:  bufferSize,
+		DSD:            true,
+		OnInput: func(_ int, in [][]byte) {
+			n := copy(in[0], dsd.Bytes())
+			dsd.data = dsd.data[n:]
+		},
+		OnOutput: func(_ int, out [][]byte) {
+			played = append(played, out[0]...)
+		},
+	})
+
+	device := Device{ResetDebounce: time.Millisecond}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	if err := device.StartDSD(func(in, out []DSDBuffer) {}); err == nil {
+		t.Fatal("StartDSD with PCM channels")
+	}
+
+	events, cancel := device.Subscribe()
+	defer cancel()
+	if err := device.SetIoFormat(IoFormatDSD); err != nil {
+		t.Fatal(err)
+	}
+	if done, ok := waitReset(t, events, time.Second); !ok || done.Err != nil {
+		t.Fatalf("reset: %v, %v", ok, done.Err)
+	}
+
+	d, err := NewDSDDecimator(DSD64SampleRate, 88200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pcm []float64
+	if err := device.StartDSD(func(in, out []DSDBuffer) {
+		pcm = d.Decimate(pcm, in[0])
+		copy(out[0].Bytes(), in[0].Bytes())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	steps := len(dsd.Bytes()) / bufferSize
+	want := slices.Clone(dsd.Bytes()[:steps*bufferSize])
+	sim.Step(steps)
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(played, want) {
+		t.Error("output differs from input")
+	}
+	if a, snr := fitSine(pcm[len(pcm)/2:], 1000, 88200); math.Abs(a-0.5) > 1e-3 || snr < 60 {
+		t.Errorf("recorded amplitude %.5f, SNR %.1f dB", a, snr)
+	}
+}
@@ -0,0 +1,91 @@
+package asio
+
+import "slices"
+
+// Event is a notification from the driver, delivered to subscribers of a Device.
+//
+// It is one of ResetRequested, ResyncRequested, LatenciesChanged, Overload,
+// SampleRateChanged, BufferSizeChange or ResetCompleted.
+type Event interface {
+	isEvent()
+}
+
+// ResetRequested is sent when the driver asks the host to reset it, for
+// instance after its settings were changed in the control panel.
+type ResetRequested struct{}
+
+// ResyncRequested is sent when the driver went out of sync and its timestamps are no longer valid.
+type ResyncRequested struct{}
+
+// LatenciesChanged is sent when the driver's latencies changed; In and Out are the refetched latencies in samples.
+type LatenciesChanged struct {
+	In, Out int
+}
+
+// Overload is sent when the driver detected an overload, i.e. processing did not finish in time.
+type Overload struct{}
+
+// SampleRateChanged is sent when the driver detected a new sample rate, such as a change of the external clock.
+type SampleRateChanged struct {
+	Rate float64
+}
+
+// BufferSizeChange is sent when the driver asks for a new buffer size in samples.
+type BufferSizeChange struct {
+	Size int
+}
+
+// ResetCompleted is sent after the device was reset following requests of the
+// driver; Err is non-nil when the reset failed, leaving the device stopped.
+type ResetCompleted struct {
+	Err error
+}
+
+func (ResetRequested) isEvent()    {}
+func (ResyncRequested) isEvent()   {}
+func (LatenciesChanged) isEvent()  {}
+func (Overload) isEvent()          {}
+func (SampleRateChanged) isEvent() {}
+func (BufferSizeChange) isEvent()  {}
+func (ResetCompleted) isEvent()    {}
+
+// eventBuffer is the capacity of subscriber channels.
+const eventBuffer = 16
+
+// Subscribe returns a channel receiving the device's events, and a function
+// to cancel the subscription and close the channel.
+//
+// Events are sent from the driver's callbacks without blocking: when the
+// channel is full, the event is dropped.
+func (dev *Device) Subscribe() (events <-chan Event, cancel func()) {
+	ch := make(chan Event, eventBuffer)
+
+	dev.subscribersMu.Lock()
+	dev.subscribers = append(dev.subscribers, ch)
+	dev.subscribersMu.Unlock()
+
+	cancel = func() {
+		dev.subscribersMu.Lock()
+		defer dev.subscribersMu.Unlock()
+
+		if i := slices.Index(dev.subscribers, ch); i >= 0 {
+			dev.subscribers = slices.Delete(dev.subscribers, i, i+1)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish delivers an event to every subscriber without blocking.
+func (dev *Device) publish(event Event) {
+	dev.subscribersMu.Lock()
+	defer dev.subscribersMu.Unlock()
+
+	for _, ch := range dev.subscribers {
+		select {
+		case ch <- event:
+		default:
+			dev.logger().Warn("event dropped, subscriber is not keeping up", "event", event)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package asio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeviceEvents(t *testing.T) {
+	sim := NewSimDriver(SimConfig{InputLatency: 100, OutputLatency: 200})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	events, cancel := device.Subscribe()
+
+	for _, selector := range []int32{kAsioResetRequest, kAsioResyncRequest, kAsioLatenciesChanged, kAsioOverload, kAsioBufferSizeChange} {
+		if sim.SendMessage(kAsioSelectorSupported, selector) != 1 {
+			t.Errorf("selector %d not supported", selector)
+		}
+	}
+	if v := sim.SendMessage(kAsioEngineVersion, 0); v != 2 {
+		t.Errorf("engine version %d, want 2", v)
+	}
+
+	sim.SendMessage(kAsioOverload, 0)
+	sim.SendMessage(kAsioLatenciesChanged, 0)
+	sim.ChangeSampleRate(48000)
+
+	want := []Event{Overload{}, LatenciesChanged{In: 100, Out: 200}, SampleRateChanged{Rate: 48000}}
+	for _, w := range want {
+		if got := <-events; !reflect.DeepEqual(got, w) {
+			t.Errorf("got event %#v, want %#v", got, w)
+		}
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("events channel not closed by cancel")
+	}
+	sim.SendMessage(kAsioOverload, 0) // no subscribers left
+}
+
+func TestDeviceEventsDropWhenFull(t *testing.T) {
+	sim := NewSimDriver(SimConfig{})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	events, cancel := device.Subscribe()
+	defer cancel()
+
+	for range 2 * eventBuffer {
+		sim.SendMessage(kAsioOverload, 0) // must not block
+	}
+	if n := len(events); n != eventBuffer {
+		t.Errorf("%d events queued, want %d", n, eventBuffer)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 func main() {
 	asio.Session{
+		DriverName: "ASIO4ALL v2",
 		IOHandler: func(in, out [][]int32) {
 			for i := range out[0] {
 				out[0][i] = in[0][i]
@@ -0,0 +1,308 @@
+package asio
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Selectors of Driver.Future:
+const (
+	FutureEnableTimeCodeRead  FutureSelector = 1 + iota // no arguments
+	FutureDisableTimeCodeRead                           // no arguments
+	FutureSetInputMonitor                               // *rawInputMonitor
+	FutureTransport                                     // *rawTransportParameters
+	FutureSetInputGain                                  // *rawChannelControls, apply gain
+	FutureGetInputMeter                                 // *rawChannelControls, fill meter
+	FutureSetOutputGain                                 // *rawChannelControls, apply gain
+	FutureGetOutputMeter                                // *rawChannelControls, fill meter
+	FutureCanInputMonitor                               // no arguments for the Can selectors
+	FutureCanTimeInfo
+	FutureCanTimeCode
+	FutureCanTransport
+	FutureCanInputGain
+	FutureCanInputMeter
+	FutureCanOutputGain
+	FutureCanOutputMeter
+	FutureOptionalOne
+
+	// DSD support
+	FutureSetIoFormat   FutureSelector = 0x23111961 // *rawIoFormat
+	FutureGetIoFormat   FutureSelector = 0x23111983 // *rawIoFormat
+	FutureCanDoIoFormat FutureSelector = 0x23112004 // *rawIoFormat
+
+	// drop out detection
+	FutureCanReportOverload        FutureSelector = 0x24042012 // no arguments
+	FutureGetInternalBufferSamples FutureSelector = 0x25042012 // *rawInternalBufferInfo
+)
+
+// NOTE: `long` is `int32` in ASIO structs regardless of `uintptr` size.
+
+type rawInputMonitor struct {
+	input  int32 // this input was set to monitor (or off), -1: all
+	output int32 // suggested output for monitoring the input (if so)
+	gain   int32 // suggested gain, ranging 0 - 0x7fffffffL (-inf to +12 dB)
+	state  int32 // ASIOTrue => on, ASIOFalse => off
+	pan    int32 // suggested pan, 0 => all left, 0x7fffffff => right
+}
+
+type rawChannelControls struct {
+	channel int32 // on input, channel index
+	isInput int32 // on input
+	gain    int32 // on input,  ranges 0 thru 0x7fffffff
+	meter   int32 // on return, ranges 0 thru 0x7fffffff
+	future  [32]byte
+}
+
+type rawTransportParameters struct {
+	command        int32
+	samplePosition asioSamples
+	track          int32
+	trackSwitches  [16]int32 // 512 tracks on/off
+	future         [64]byte
+}
+
+type rawIoFormat struct {
+	formatType int32
+	future     [512 - 4]byte
+}
+
+type rawInternalBufferInfo struct {
+	inputSamples  int32 // size of driver's internal input buffering which is included in getLatencies
+	outputSamples int32 // size of driver's internal output buffering which is included in getLatencies
+}
+
+// InputMonitor configures direct monitoring of an input by the hardware.
+type InputMonitor struct {
+	Input  int   // input channel, -1 for all
+	Output int   // suggested output channel for monitoring the input
+	Gain   int32 // suggested gain, 0 (-inf) to 0x7fffffff (+12 dB)
+	On     bool
+	Pan    int32 // suggested pan, 0 (left) to 0x7fffffff (right)
+}
+
+// TransportCommand is a command of Transport.
+type TransportCommand int32
+
+const (
+	TransportStart TransportCommand = 1 + iota
+	TransportStop
+	TransportLocate // to SamplePosition
+	TransportPunchIn
+	TransportPunchOut
+	TransportArmOn      // Track
+	TransportArmOff     // Track
+	TransportMonitorOn  // Track
+	TransportMonitorOff // Track
+	TransportArm        // TrackSwitches
+	TransportMonitor    // TrackSwitches
+)
+
+// TransportParameters control the transport of the driver's hardware, such as a tape machine.
+type TransportParameters struct {
+	Command        TransportCommand
+	SamplePosition int64
+	Track          int
+	TrackSwitches  [16]uint32 // bit mask of 512 tracks
+}
+
+// IoFormat is the format of the driver's buffers, switched with SetIoFormat.
+type IoFormat int32
+
+const (
+	IoFormatInvalid IoFormat = -1
+	IoFormatPCM     IoFormat = 0
+	IoFormatDSD     IoFormat = 1
+)
+
+func (f IoFormat) String() string {
+	switch f {
+	case IoFormatInvalid:
+		return "Invalid"
+	case IoFormatPCM:
+		return "PCM"
+	case IoFormatDSD:
+		return "DSD"
+	}
+	return fmt.Sprintf("IoFormat(%d)", int32(f))
+}
+
+// futureCoder is implemented by the drivers of this package, which keep the
+// result code of Future: ASE_SUCCESS, or ASE_OK from drivers answering
+// selectors they do not implement.
+type futureCoder interface {
+	futureCode(selector FutureSelector, opt unsafe.Pointer) (int32, error)
+}
+
+// future calls a selector like Device.Future, except that ErrorNotPresent,
+// by which drivers tell the feature is unsupported, is wrapped into errors.ErrUnsupported.
+func (dev *Device) future(op string, selector FutureSelector, opt unsafe.Pointer) error {
+	_, err := dev.futureCode(op, selector, opt)
+	return err
+}
+
+// futureCode is future returning the result code of a successful call too.
+// Drivers not implementing futureCoder are taken to answer ASE_SUCCESS.
+func (dev *Device) futureCode(op string, selector FutureSelector, opt unsafe.Pointer) (int32, error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	drv, err := dev.getDriver(op)
+	if err != nil {
+		return 0, err
+	}
+	code := int32(ASE_SUCCESS)
+	if fc, ok := drv.(futureCoder); ok {
+		code, err = fc.futureCode(selector, opt)
+	} else {
+		err = drv.Future(selector, opt)
+	}
+	err = dev.driverError("Future", err)
+	switch {
+	case errors.Is(err, ErrorNotPresent):
+		return 0, fmt.Errorf("%s: %w: %w", op, errors.ErrUnsupported, err)
+	case err != nil:
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return code, nil
+}
+
+// can asks the driver about an optional feature, supported only when the
+// driver answers ASE_SUCCESS. Drivers not knowing the selector at all answer
+// ErrorInvalidParameter, or ASE_OK, which mean no as well.
+func (dev *Device) can(op string, selector FutureSelector, opt unsafe.Pointer) (bool, error) {
+	code, err := dev.futureCode(op, selector, opt)
+	if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, ErrorInvalidParameter) {
+		return false, nil
+	}
+	return err == nil && code == ASE_SUCCESS, err
+}
+
+// The methods below wrap the documented selectors of Driver.Future. Calls
+// the driver does not support fail with errors.ErrUnsupported; the Can
+// methods report support instead.
+
+// EnableTimeCodeRead makes the driver read time code, reported in TimeInfo.TimeCode.
+func (dev *Device) EnableTimeCodeRead() error {
+	return dev.future("EnableTimeCodeRead", FutureEnableTimeCodeRead, nil)
+}
+
+func (dev *Device) DisableTimeCodeRead() error {
+	return dev.future("DisableTimeCodeRead", FutureDisableTimeCodeRead, nil)
+}
+
+// SetInputMonitor switches direct monitoring of an input on or off.
+func (dev *Device) SetInputMonitor(m InputMonitor) error {
+	raw := rawInputMonitor{
+		input:  int32(m.Input),
+		output: int32(m.Output),
+		gain:   m.Gain,
+		state:  bool_int32(m.On),
+		pan:    m.Pan,
+	}
+	return dev.future("SetInputMonitor", FutureSetInputMonitor, unsafe.Pointer(&raw))
+}
+
+// Transport sends a transport command to the hardware.
+func (dev *Device) Transport(p TransportParameters) error {
+	raw := rawTransportParameters{
+		command:        int32(p.Command),
+		samplePosition: newASIOSamples(p.SamplePosition),
+		track:          int32(p.Track),
+	}
+	for i, s := range p.TrackSwitches {
+		raw.trackSwitches[i] = int32(s)
+	}
+	return dev.future("Transport", FutureTransport, unsafe.Pointer(&raw))
+}
+
+// SetInputGain sets the gain of an input channel, from 0 to 0x7fffffff.
+func (dev *Device) SetInputGain(channel int, gain int32) error {
+	raw := rawChannelControls{channel: int32(channel), isInput: 1, gain: gain}
+	return dev.future("SetInputGain", FutureSetInputGain, unsafe.Pointer(&raw))
+}
+
+// SetOutputGain sets the gain of an output channel, from 0 to 0x7fffffff.
+func (dev *Device) SetOutputGain(channel int, gain int32) error {
+	raw := rawChannelControls{channel: int32(channel), isInput: 0, gain: gain}
+	return dev.future("SetOutputGain", FutureSetOutputGain, unsafe.Pointer(&raw))
+}
+
+// GetInputMeter returns the level of an input channel, from 0 to 0x7fffffff.
+func (dev *Device) GetInputMeter(channel int) (int32, error) {
+	raw := rawChannelControls{channel: int32(channel), isInput: 1}
+	err := dev.future("GetInputMeter", FutureGetInputMeter, unsafe.Pointer(&raw))
+	return raw.meter, err
+}
+
+// GetOutputMeter returns the level of an output channel, from 0 to 0x7fffffff.
+func (dev *Device) GetOutputMeter(channel int) (int32, error) {
+	raw := rawChannelControls{channel: int32(channel), isInput: 0}
+	err := dev.future("GetOutputMeter", FutureGetOutputMeter, unsafe.Pointer(&raw))
+	return raw.meter, err
+}
+
+func (dev *Device) CanInputMonitor() (bool, error) {
+	return dev.can("CanInputMonitor", FutureCanInputMonitor, nil)
+}
+
+func (dev *Device) CanTimeInfo() (bool, error) {
+	return dev.can("CanTimeInfo", FutureCanTimeInfo, nil)
+}
+
+func (dev *Device) CanTimeCode() (bool, error) {
+	return dev.can("CanTimeCode", FutureCanTimeCode, nil)
+}
+
+func (dev *Device) CanTransport() (bool, error) {
+	return dev.can("CanTransport", FutureCanTransport, nil)
+}
+
+func (dev *Device) CanInputGain() (bool, error) {
+	return dev.can("CanInputGain", FutureCanInputGain, nil)
+}
+
+func (dev *Device) CanInputMeter() (bool, error) {
+	return dev.can("CanInputMeter", FutureCanInputMeter, nil)
+}
+
+func (dev *Device) CanOutputGain() (bool, error) {
+	return dev.can("CanOutputGain", FutureCanOutputGain, nil)
+}
+
+func (dev *Device) CanOutputMeter() (bool, error) {
+	return dev.can("CanOutputMeter", FutureCanOutputMeter, nil)
+}
+
+// CanReportOverload reports whether the driver detects overloads, sent as Overload events.
+func (dev *Device) CanReportOverload() (bool, error) {
+	return dev.can("CanReportOverload", FutureCanReportOverload, nil)
+}
+
+// SetIoFormat switches the driver between PCM and DSD. Drivers usually
+// request a reset afterwards, which the device performs on its own; the
+// sample types of the channels change accordingly.
+func (dev *Device) SetIoFormat(format IoFormat) error {
+	raw := rawIoFormat{formatType: int32(format)}
+	return dev.future("SetIoFormat", FutureSetIoFormat, unsafe.Pointer(&raw))
+}
+
+func (dev *Device) GetIoFormat() (IoFormat, error) {
+	raw := rawIoFormat{formatType: int32(IoFormatInvalid)}
+	if err := dev.future("GetIoFormat", FutureGetIoFormat, unsafe.Pointer(&raw)); err != nil {
+		return IoFormatInvalid, err
+	}
+	return IoFormat(raw.formatType), nil
+}
+
+func (dev *Device) CanDoIoFormat(format IoFormat) (bool, error) {
+	raw := rawIoFormat{formatType: int32(format)}
+	return dev.can("CanDoIoFormat", FutureCanDoIoFormat, unsafe.Pointer(&raw))
+}
+
+// GetInternalBufferSamples returns the size of the driver's internal buffering, which is included in GetLatencies.
+func (dev *Device) GetInternalBufferSamples() (inputSamples, outputSamples int, err error) {
+	var raw rawInternalBufferInfo
+	err = dev.future("GetInternalBufferSamples", FutureGetInternalBufferSamples, unsafe.Pointer(&raw))
+	return int(raw.inputSamples), int(raw.outputSamples), err
+}
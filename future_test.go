@@ -0,0 +1,131 @@
+package asio
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestDeviceFuture(t *testing.T) {
+	sim := NewSimDriver(SimConfig{InputChannels: 2, OutputChannels: 2})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	for name, can := range map[string]func() (bool, error){
+		"CanTimeInfo":       device.CanTimeInfo,
+		"CanReportOverload": device.CanReportOverload,
+		"CanInputMonitor":   device.CanInputMonitor,
+	} {
+		if ok, err := can(); !ok || err != nil {
+			t.Errorf("%s() = %v, %v, want true", name, ok, err)
+		}
+	}
+	for name, can := range map[string]func() (bool, error){
+		"CanTimeCode":    device.CanTimeCode,
+		"CanTransport":   device.CanTransport,
+		"CanInputGain":   device.CanInputGain,
+		"CanOutputMeter": device.CanOutputMeter,
+	} {
+		if ok, err := can(); ok || err != nil {
+			t.Errorf("%s() = %v, %v, want false", name, ok, err)
+		}
+	}
+	if ok, err := device.CanDoIoFormat(IoFormatDSD); ok || err != nil {
+		t.Errorf("CanDoIoFormat(DSD) = %v, %v, want false", ok, err)
+	}
+
+	if err := device.EnableTimeCodeRead(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("EnableTimeCodeRead: %v, want ErrUnsupported", err)
+	}
+	if err := device.Transport(TransportParameters{Command: TransportStart}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Transport: %v, want ErrUnsupported", err)
+	}
+	if _, err := device.GetInputMeter(0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("GetInputMeter: %v, want ErrUnsupported", err)
+	}
+
+	if err := device.SetInputMonitor(InputMonitor{Input: 1, Output: 0, Gain: 0x7fffffff, On: true}); err != nil {
+		t.Error(err)
+	}
+	err := device.SetInputMonitor(InputMonitor{Input: 5, Output: 0, On: true})
+	if !errors.Is(err, ErrorInvalidParameter) || errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("SetInputMonitor of a missing input: %v, want %v", err, ErrorInvalidParameter)
+	}
+	if in, out, err := device.GetInternalBufferSamples(); in != 0 || out != 0 || err != nil {
+		t.Errorf("GetInternalBufferSamples() = %d, %d, %v", in, out, err)
+	}
+	if err := device.Future(FutureOptionalOne+100, nil); !errors.Is(err, ErrorInvalidParameter) {
+		t.Errorf("unknown selector: %v", err)
+	}
+
+	// ASE_OK, which drivers answer to selectors they ignore, is not a yes
+	lax := Device{}
+	if err := lax.LoadDriver(okFutureDriver{sim}); err != nil {
+		t.Fatal(err)
+	}
+	defer lax.Unload()
+	if ok, err := lax.CanTimeInfo(); ok || err != nil {
+		t.Errorf("CanTimeInfo() answered ASE_OK = %v, %v, want false", ok, err)
+	}
+	if err := lax.Future(FutureCanTimeInfo, nil); err != nil {
+		t.Errorf("Future answered ASE_OK: %v", err)
+	}
+}
+
+// okFutureDriver answers ASE_OK to the selectors the sim succeeds in.
+type okFutureDriver struct{ *SimDriver }
+
+func (d okFutureDriver) futureCode(selector FutureSelector, opt unsafe.Pointer) (int32, error) {
+	if _, err := d.SimDriver.futureCode(selector, opt); err != nil {
+		return 0, err
+	}
+	return ASE_OK, nil
+}
+
+func TestDeviceSetIoFormat(t *testing.T) {
+	sim := NewSimDriver(SimConfig{DSD: true})
+
+	device := Device{ResetDebounce: time.Millisecond}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	if ok, err := device.CanDoIoFormat(IoFormatDSD); !ok || err != nil {
+		t.Errorf("CanDoIoFormat(DSD) = %v, %v, want true", ok, err)
+	}
+	if format, err := device.GetIoFormat(); format != IoFormatPCM || err != nil {
+		t.Errorf("GetIoFormat() = %v, %v, want PCM", format, err)
+	}
+
+	events, cancel := device.Subscribe()
+	defer cancel()
+
+	// the driver asks for a reset, after which the buffers hold DSD
+	if err := device.SetIoFormat(IoFormatDSD); err != nil {
+		t.Fatal(err)
+	}
+	done, ok := waitReset(t, events, time.Second)
+	if !ok || done.Err != nil {
+		t.Fatalf("reset: %v, %v", ok, done.Err)
+	}
+
+	if format, err := device.GetIoFormat(); format != IoFormatDSD || err != nil {
+		t.Errorf("GetIoFormat() = %v, %v, want DSD", format, err)
+	}
+	if in := device.InputChannels(); in[0].SampleType != ASIOSTDSDInt8MSB1 {
+		t.Errorf("input sample type %v after switching to DSD", in[0].SampleType)
+	}
+	if rate, _ := device.GetSampleRate(); rate != 2822400 {
+		t.Errorf("sample rate %v in DSD mode, want 2822400", rate)
+	}
+}
@@ -0,0 +1,25 @@
+package asio
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler drops every record. It backs the default logger, so the
+// package stays silent unless a Logger is provided.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// loggerOrDiscard returns logger, or a logger dropping everything when it is nil.
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package asio
 
 import (
@@ -35,13 +37,6 @@ func RegOpenKey(key syscall.Handle, subkey string, desiredAccess uint32) (handle
 	return
 }
 
-type GUID struct {
-	Data1 uint32
-	Data2 uint16
-	Data3 uint16
-	Data4 [8]byte
-}
-
 type pIUnknownVtbl struct {
 	pQueryInterface uintptr
 	pAddRef         uintptr
@@ -0,0 +1,305 @@
+package asio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PCMFormat is a format of interleaved little-endian PCM samples, named like in ffmpeg.
+type PCMFormat string
+
+const (
+	PCMS16LE PCMFormat = "s16le"
+	PCMS24LE PCMFormat = "s24le" // packed in 3 bytes
+	PCMS32LE PCMFormat = "s32le"
+	PCMF32LE PCMFormat = "f32le"
+)
+
+var pcmFormatTypes = map[PCMFormat]SampleType{
+	PCMS16LE: ASIOSTInt16LSB,
+	PCMS24LE: ASIOSTInt24LSB,
+	PCMS32LE: ASIOSTInt32LSB,
+	PCMF32LE: ASIOSTFloat32LSB,
+}
+
+// SampleType returns the ASIO sample type with the same layout as f.
+func (f PCMFormat) SampleType() (SampleType, error) {
+	t, ok := pcmFormatTypes[f]
+	if !ok {
+		return 0, fmt.Errorf("unknown PCM format %q", f)
+	}
+	return t, nil
+}
+
+// pcmAdapter converts between the buffers of a stream and interleaved PCM.
+type pcmAdapter struct {
+	codec    Codec
+	channels []int                // selected channels of the stream
+	floats   *pcmBuffers[float32] // nil when ints is used
+	ints     *pcmBuffers[int32]   // integer formats on a stream of int32 samples
+	raw      []byte               // encoded samples
+	pending  []byte               // bytes of raw not returned yet, or of a partial frame
+}
+
+// pcmBuffers holds the samples of a pcmAdapter, in the type read from or
+// written to the stream.
+type pcmBuffers[T int32 | float32] struct {
+	bufs    [][]T // one per channel of the stream
+	views   [][]T // frames of bufs passed to the stream
+	samples []T   // interleaved selected channels
+}
+
+func newPCMAdapter(s *Stream, format PCMFormat, channels []int, numChannels, frames int) (*pcmAdapter, error) {
+	t, err := format.SampleType()
+	if err != nil {
+		return nil, err
+	}
+	codec, err := NewCodec(t)
+	if err != nil {
+		return nil, err
+	}
+	if channels == nil {
+		channels = make([]int, numChannels)
+		for i := range channels {
+			channels[i] = i
+		}
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("no channels selected")
+	}
+	for _, c := range channels {
+		if c < 0 || c >= numChannels {
+			return nil, fmt.Errorf("channel %d out of %d", c, numChannels)
+		}
+	}
+
+	a := &pcmAdapter{
+		codec:    codec,
+		channels: channels,
+		raw:      make([]byte, frames*len(channels)*codec.Size()),
+	}
+	if s.ints && !codec.IsFloat() {
+		a.ints = newPCMBuffers[int32](numChannels, frames, len(channels))
+	} else {
+		a.floats = newPCMBuffers[float32](numChannels, frames, len(channels))
+	}
+	return a, nil
+}
+
+func newPCMBuffers[T int32 | float32](numChannels, frames, selected int) *pcmBuffers[T] {
+	b := &pcmBuffers[T]{
+		bufs:    make([][]T, numChannels),
+		views:   make([][]T, numChannels),
+		samples: make([]T, frames*selected),
+	}
+	for i := range b.bufs {
+		b.bufs[i] = make([]T, frames)
+	}
+	return b
+}
+
+// view returns the frames from lo to hi of the channels.
+func (b *pcmBuffers[T]) view(lo, hi int) [][]T {
+	for i, buf := range b.bufs {
+		b.views[i] = buf[lo:hi]
+	}
+	return b.views
+}
+
+// frameSize returns the size of an interleaved frame in bytes.
+func (a *pcmAdapter) frameSize() int {
+	return len(a.channels) * a.codec.Size()
+}
+
+// encodeInts encodes samples scaled to the full range of int32, overwriting src.
+func (a *pcmAdapter) encodeInts(dst []byte, src []int32) int {
+	for i, v := range src {
+		src[i] = requantize(v, a.codec.Bits())
+	}
+	return a.codec.EncodeInt32(dst, src)
+}
+
+// decodeInts decodes samples scaled to the full range of int32.
+func (a *pcmAdapter) decodeInts(dst []int32, src []byte) int {
+	n := a.codec.DecodeInt32(dst, src)
+	for i := range dst[:n] {
+		dst[i] <<= 32 - a.codec.Bits()
+	}
+	return n
+}
+
+// PCMReader reads input channels of a Stream as interleaved PCM. Created by Stream.PCMReader.
+type PCMReader struct {
+	s *Stream
+	*pcmAdapter
+}
+
+// PCMReader returns a reader of the given input channels, by index in
+// InputChannels, interleaved in that order; nil selects every channel.
+//
+// Read returns the frames already recorded, waiting for the driver only when
+// there are none. It returns io.EOF once the stream is closed and drained.
+// Frames dropped because the reader was too slow do not stop reading, so that
+// io.Copy goes on; they are counted by Dropped.
+func (s *Stream) PCMReader(format PCMFormat, channels []int) (*PCMReader, error) {
+	if s.in == nil {
+		return nil, errors.New("stream: no input channels")
+	}
+	a, err := newPCMAdapter(s, format, channels, s.in.Channels(), s.in.Cap())
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+	return &PCMReader{s, a}, nil
+}
+
+func (r *PCMReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.pending) == 0 {
+		var err error
+		if r.ints != nil {
+			err = readPCM(r, r.ints, len(p), r.encodeInts)
+		} else {
+			err = readPCM(r, r.floats, len(p), r.codec.EncodeFloat32)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// readPCM reads the frames of a call to Read for want bytes into r.pending.
+func readPCM[T int32 | float32](r *PCMReader, b *pcmBuffers[T], want int, encode func(dst []byte, src []T) int) error {
+	// at least a frame, copied in parts if fewer bytes are wanted; only the frames
+	// available are read, waiting for one if there are none
+	frames := min(max(want/r.frameSize(), 1), len(b.bufs[0]))
+	n := min(frames, max(r.s.ReadAvailable(), 1))
+	err := streamRead(r.s, b.view(0, n))
+	if err == nil || errors.Is(err, ErrInputOverflow) {
+		err = nil
+		if more := min(frames-n, r.s.ReadAvailable()); more > 0 {
+			if err = streamRead(r.s, b.view(n, n+more)); errors.Is(err, ErrInputOverflow) {
+				err = nil
+			}
+			n += more
+		}
+	}
+	if errors.Is(err, ErrStreamClosed) {
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+	frames = n
+
+	samples := b.samples[:frames*len(r.channels)]
+	for i := range frames {
+		for j, c := range r.channels {
+			samples[i*len(r.channels)+j] = b.bufs[c][i]
+		}
+	}
+	encode(r.raw, samples)
+	r.pending = r.raw[:len(samples)*r.codec.Size()]
+	return nil
+}
+
+// Dropped returns the number of input frames dropped since the stream was
+// opened because they were not read in time.
+func (r *PCMReader) Dropped() uint64 {
+	return r.s.in.Overruns()
+}
+
+// PCMWriter writes interleaved PCM to output channels of a Stream. Created by Stream.PCMWriter.
+type PCMWriter struct {
+	s *Stream
+	*pcmAdapter
+}
+
+// PCMWriter returns a writer to the given output channels, by index in
+// OutputChannels, interleaved in that order; nil selects every channel.
+// Channels not selected are silent.
+//
+// Write queues whole frames, keeping a partial frame until the next call;
+// it waits while the configured number of buffers are queued. Frames the driver
+// played as silence because they were not written in time do not stop writing,
+// so that io.Copy goes on; they are counted by Underruns.
+func (s *Stream) PCMWriter(format PCMFormat, channels []int) (*PCMWriter, error) {
+	if s.out == nil {
+		return nil, errors.New("stream: no output channels")
+	}
+	a, err := newPCMAdapter(s, format, channels, s.out.Channels(), s.out.Cap())
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+	a.pending = a.raw[:0]
+	return &PCMWriter{s, a}, nil
+}
+
+func (w *PCMWriter) Write(p []byte) (int, error) {
+	written := 0
+	frameSize := w.frameSize()
+	for len(p) > 0 {
+		// complete a partial frame first, then take whole frames from p directly
+		var raw []byte
+		if len(w.pending) > 0 || len(p) < frameSize {
+			n := copy(w.raw[len(w.pending):frameSize], p)
+			w.pending = w.raw[:len(w.pending)+n]
+			p, written = p[n:], written+n
+			if len(w.pending) < frameSize {
+				break
+			}
+			raw, w.pending = w.pending, w.raw[:0]
+		} else {
+			n := min(len(p)/frameSize, w.frames()) * frameSize
+			raw, p = p[:n], p[n:]
+			written += n
+		}
+
+		var err error
+		if w.ints != nil {
+			err = writePCM(w, w.ints, raw, w.decodeInts)
+		} else {
+			err = writePCM(w, w.floats, raw, w.codec.DecodeFloat32)
+		}
+		if err != nil && !errors.Is(err, ErrOutputUnderflow) {
+			return max(written-len(raw), 0), err
+		}
+	}
+	return written, nil
+}
+
+// frames returns the number of frames of the buffers.
+func (a *pcmAdapter) frames() int {
+	if a.ints != nil {
+		return len(a.ints.bufs[0])
+	}
+	return len(a.floats.bufs[0])
+}
+
+// writePCM writes the whole frames of raw to the stream.
+func writePCM[T int32 | float32](w *PCMWriter, b *pcmBuffers[T], raw []byte, decode func(dst []T, src []byte) int) error {
+	frames := len(raw) / w.frameSize()
+	samples := b.samples[:frames*len(w.channels)]
+	decode(samples, raw)
+	bufs := b.view(0, frames)
+	for _, buf := range bufs {
+		clear(buf)
+	}
+	for i := range frames {
+		for j, c := range w.channels {
+			bufs[c][i] = samples[i*len(w.channels)+j]
+		}
+	}
+	return streamWrite(w.s, bufs)
+}
+
+// Underruns returns the number of output frames played as silence since the
+// stream was opened because they were not written in time.
+func (w *PCMWriter) Underruns() uint64 {
+	return w.s.out.Underruns()
+}
@@ -0,0 +1,266 @@
+package asio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+// newPCMSim returns a float32 sim recording a sine of a different frequency
+// on each input, and functions returning what it recorded and played.
+func newPCMSim(channels, bufferSize int) (sim *SimDriver, sent, played func() [][]float32) {
+	var mu sync.Mutex
+	in, out := make([][]float32, channels), make([][]float32, channels)
+	sim = NewSimDriver(SimConfig{
+		InputChannels:  channels,
+		OutputChannels: channels,
+		PreferredSize:  bufferSize,
+		SampleType:     ASIOSTFloat32LSB,
+		OnInput: func(_ int, bufs [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for c, buf := range bufs {
+				for i := 0; i < len(buf); i += 4 {
+					v := float32(0.9 * math.Sin(float64(len(in[c]))*0.01*float64(c+1)))
+					binary.LittleEndian.PutUint32(buf[i:], math.Float32bits(v))
+					in[c] = append(in[c], v)
+				}
+			}
+		},
+		OnOutput: func(_ int, bufs [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for c, buf := range bufs {
+				for i := 0; i < len(buf); i += 4 {
+					out[c] = append(out[c], math.Float32frombits(binary.LittleEndian.Uint32(buf[i:])))
+				}
+			}
+		},
+	})
+	get := func(bufs [][]float32) func() [][]float32 {
+		return func() [][]float32 {
+			mu.Lock()
+			defer mu.Unlock()
+			clone := make([][]float32, len(bufs))
+			for c := range bufs {
+				clone[c] = slices.Clone(bufs[c])
+			}
+			return clone
+		}
+	}
+	return sim, get(in), get(out)
+}
+
+func TestPCM(t *testing.T) {
+	const bufferSize, buffers = 64, 4
+	for _, format := range []PCMFormat{PCMS16LE, PCMS24LE, PCMS32LE, PCMF32LE} {
+		sim, sent, played := newPCMSim(2, bufferSize)
+		s := openStream(t, sim, StreamOptions{Buffers: buffers})
+		// channels swapped in the interleaved bytes, and back to the outputs
+		r, err := s.PCMReader(format, []int{1, 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := s.PCMWriter(format, []int{1, 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		// read one byte at a time, and write in chunks of 7 bytes across frames
+		sim.Step(buffers)
+		data := make([]byte, buffers*bufferSize*2*r.codec.Size())
+		if _, err := io.ReadFull(iotest.OneByteReader(r), data); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for rest := data; len(rest) > 0; {
+			n := min(len(rest), 7)
+			if m, err := w.Write(rest[:n]); m != n || err != nil {
+				t.Fatalf("%s: Write() = %d, %v", format, m, err)
+			}
+			rest = rest[n:]
+		}
+		sim.Step(buffers)
+
+		c := r.codec
+		in, out := sent(), played()
+		for ch := range in {
+			recorded := in[ch][:buffers*bufferSize]
+			raw := make([]byte, len(recorded)*c.Size())
+			want := make([]float32, len(recorded))
+			c.EncodeFloat32(raw, recorded)
+			c.DecodeFloat32(want, raw)
+			if got := out[ch][buffers*bufferSize:]; !slices.Equal(got, want) {
+				t.Errorf("%s: channel %d played %v, want %v", format, ch, got, want)
+			}
+		}
+
+		// the first frame holds channel 1 then channel 0
+		first := make([]float32, 2)
+		c.DecodeFloat32(first, data[:2*c.Size()])
+		if first[0] != out[1][buffers*bufferSize] || first[1] != out[0][buffers*bufferSize] {
+			t.Errorf("%s: first frame %v", format, first)
+		}
+
+		// frames recorded before Close are still read
+		s.Close()
+		if rest, err := io.ReadAll(r); len(rest) != len(data) || err != nil {
+			t.Errorf("%s: read %d bytes after Close, want %d: %v", format, len(rest), len(data), err)
+		}
+		if _, err := w.Write(data); !errors.Is(err, ErrStreamClosed) {
+			t.Errorf("%s: Write() after Close = %v", format, err)
+		}
+	}
+}
+
+func TestPCMXruns(t *testing.T) {
+	const bufferSize, buffers = 64, 2
+	sim, _, _ := newPCMSim(2, bufferSize)
+	s := openStream(t, sim, StreamOptions{Buffers: buffers})
+	r, err := s.PCMReader(PCMS16LE, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.PCMWriter(PCMS16LE, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing read nor written while the driver runs: io.Copy is not stopped
+	sim.Step(2 * buffers)
+	if n, err := w.Write(make([]byte, 4*bufferSize)); n != 4*bufferSize || err != nil {
+		t.Errorf("Write() after an underflow = %d, %v", n, err)
+	}
+	s.Close()
+	if n, err := io.Copy(io.Discard, r); n != buffers*bufferSize*4 || err != nil {
+		t.Errorf("io.Copy() after an overflow = %d, %v", n, err)
+	}
+	if r.Dropped() != buffers*bufferSize {
+		t.Errorf("%d frames dropped, want %d", r.Dropped(), buffers*bufferSize)
+	}
+	if w.Underruns() != 2*buffers*bufferSize {
+		t.Errorf("%d frames missing, want %d", w.Underruns(), 2*buffers*bufferSize)
+	}
+}
+
+func TestPCMWriterChannels(t *testing.T) {
+	const bufferSize = 64
+	sim, _, played := newPCMSim(2, bufferSize)
+	s := openStream(t, sim, StreamOptions{})
+	w, err := s.PCMWriter(PCMS16LE, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 2*bufferSize)
+	for i := range bufferSize {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(i<<8))
+	}
+	if n, err := w.Write(data); n != len(data) || err != nil {
+		t.Fatalf("Write() = %d, %v", n, err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+
+	out := played()
+	for i := range bufferSize {
+		if want := float32(i) / 128; out[0][i] != 0 || out[1][i] != want {
+			t.Fatalf("frame %d played as %v, %v, want 0, %v", i, out[0][i], out[1][i], want)
+		}
+	}
+
+	for _, tc := range []struct {
+		format   PCMFormat
+		channels []int
+	}{
+		{"u8", nil},
+		{PCMS16LE, []int{2}},
+		{PCMS16LE, []int{}},
+	} {
+		if _, err := s.PCMReader(tc.format, tc.channels); err == nil {
+			t.Errorf("reader of %s on channels %v", tc.format, tc.channels)
+		}
+		if _, err := s.PCMWriter(tc.format, tc.channels); err == nil {
+			t.Errorf("writer of %s on channels %v", tc.format, tc.channels)
+		}
+	}
+}
+
+func TestPCMInt32(t *testing.T) {
+	// a 32 bit integer driver is streamed as s32le without loss
+	values := []int32{math.MaxInt32, 1, -1, math.MinInt32, 0x12348000, -0x12348000, 0x7fff, 0}
+	var raw []byte
+	for _, v := range values {
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(v))
+	}
+	var mu sync.Mutex
+	var played []byte
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 1,
+		MinSize:        len(values),
+		PreferredSize:  len(values),
+		SampleType:     ASIOSTInt32LSB,
+		OnInput: func(_ int, bufs [][]byte) {
+			copy(bufs[0], raw)
+		},
+		OnOutput: func(_ int, bufs [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			played = append(played, bufs[0]...)
+		},
+	})
+	s := openStream(t, sim, StreamOptions{})
+	r, err := s.PCMReader(PCMS32LE, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.PCMWriter(PCMS32LE, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	data := make([]byte, len(raw))
+	if _, err := io.ReadFull(r, data); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(data, raw) {
+		t.Errorf("read % x, want % x", data, raw)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// and through ReadInt32 and WriteInt32
+	sim.Step(1)
+	buf := [][]int32{make([]int32, len(values))}
+	if err := s.ReadInt32(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(buf[0], values) {
+		t.Errorf("ReadInt32 read %v, want %v", buf[0], values)
+	}
+	if err := s.WriteInt32(buf); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	mu.Lock()
+	defer mu.Unlock()
+	if want := slices.Concat(make([]byte, len(raw)), raw, raw); !slices.Equal(played, want) {
+		t.Errorf("played % x, want % x", played, want)
+	}
+}
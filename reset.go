@@ -0,0 +1,200 @@
+package asio
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// defaultResetDebounce is used when Device.ResetDebounce is zero.
+const defaultResetDebounce = 50 * time.Millisecond
+
+// resetRequest describes a reset asked for by the driver. Requests arriving
+// within the debounce period are merged into one.
+type resetRequest struct {
+	reinit     bool // reinitialize the driver, not only its buffers
+	bufferSize int  // buffer size asked for by the driver, or 0
+}
+
+func (r resetRequest) merge(other resetRequest) resetRequest {
+	r.reinit = r.reinit || other.reinit
+	if other.bufferSize != 0 {
+		r.bufferSize = other.bufferSize
+	}
+	return r
+}
+
+// resetSupervisor performs the resets requested from the driver's callbacks
+// on its own goroutine: the driver cannot be stopped nor its buffers disposed
+// from within its callbacks.
+type resetSupervisor struct {
+	mu      sync.Mutex
+	pending resetRequest
+	signal  chan struct{} // nil when the supervisor is not running
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+func (dev *Device) startSupervisor() {
+	s := &dev.reset
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.signal != nil {
+		return
+	}
+	s.pending = resetRequest{}
+	s.signal = make(chan struct{}, 1)
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+	go dev.supervise(s.signal, s.quit, s.done)
+}
+
+// stopSupervisor stops the supervisor and waits for a reset in progress to finish.
+// Pending requests are dropped.
+func (dev *Device) stopSupervisor() {
+	s := &dev.reset
+	s.mu.Lock()
+	quit, done := s.quit, s.done
+	s.signal, s.quit, s.done = nil, nil, nil
+	s.mu.Unlock()
+
+	if quit == nil {
+		return
+	}
+	close(quit)
+	<-done
+}
+
+// requestReset queues a reset without blocking; it is safe to call from the driver's callbacks.
+func (dev *Device) requestReset(req resetRequest) {
+	s := &dev.reset
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.signal == nil {
+		dev.logger().Warn("reset request dropped, driver not loaded")
+		return
+	}
+	s.pending = s.pending.merge(req)
+	select {
+	case s.signal <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+func (dev *Device) supervise(signal, quit <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	// reinit creates the COM object of the driver again from this goroutine
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	coInitialize()
+	defer coUninitialize()
+
+	debounce := dev.ResetDebounce
+	if debounce <= 0 {
+		debounce = defaultResetDebounce
+	}
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-signal:
+		}
+
+		// wait until the driver stopped asking
+		timer := time.NewTimer(debounce)
+		for waiting := true; waiting; {
+			select {
+			case <-quit:
+				timer.Stop()
+				return
+			case <-signal:
+				timer.Reset(debounce)
+			case <-timer.C:
+				waiting = false
+			}
+		}
+
+		dev.reset.mu.Lock()
+		req := dev.reset.pending
+		dev.reset.pending = resetRequest{}
+		dev.reset.mu.Unlock()
+
+		dev.lock()
+		err := dev.resetLocked(req)
+		if err != nil {
+			dev.logger().Error("reset failed", "err", err)
+		} else {
+			dev.logger().Info("reset completed", "reinit", req.reinit, "buffer_size", dev.bufferSize)
+		}
+		dev.mu.Unlock()
+
+		dev.publish(ResetCompleted{Err: err})
+	}
+}
+
+// resetLocked stops the driver and recreates its buffers with the options of
+// the last Open, then restarts it if it was running. dev.mu must be held, by lock.
+func (dev *Device) resetLocked(req resetRequest) error {
+	if _, err := dev.getDriver("Reset"); err != nil {
+		return err
+	}
+
+	wasRunning, opened := dev.state == StateRunning, dev.state == StatePrepared || dev.state == StateRunning
+	if err := dev.close(); err != nil {
+		return err
+	}
+	if req.reinit {
+		if err := dev.reinit(); err != nil {
+			return err
+		}
+	}
+	if !opened {
+		return nil
+	}
+
+	opts := dev.options
+	if req.bufferSize != 0 {
+		opts.BufferSize, opts.Latency = req.bufferSize, 0
+	}
+	if err := dev.openWith(opts); err != nil {
+		return err
+	}
+	if wasRunning {
+		return dev.start()
+	}
+	return nil
+}
+
+// reinit reinitializes the driver, reopening it when it was loaded by name,
+// and restores the sample rate and clock source last set. On failure the device is left
+// Loaded, and only Unload is allowed.
+func (dev *Device) reinit() error {
+	dev.state = StateLoaded
+	if dev.driver != nil {
+		dev.driver.Close()
+		dev.drv = nil
+		if err := dev.driver.Open(); err != nil {
+			return err
+		}
+		dev.drv = dev.driver.ASIO
+	} else if ok := dev.drv.Init(uintptr(0)); !ok {
+		return initError(dev.driverName, dev.drv)
+	}
+	dev.state = StateInitialized
+
+	if rate := dev.currentSampleRate; rate != 0 {
+		if err := dev.drv.SetSampleRate(rate); err != nil {
+			dev.logger().Warn("sample rate not restored after reset", "rate", rate, "err", err)
+		}
+	}
+	if dev.clockSourceSet {
+		if err := dev.drv.SetClockSource(dev.clockSource); err != nil {
+			dev.logger().Warn("clock source not restored after reset", "index", dev.clockSource, "err", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package asio
+
+import (
+	"testing"
+	"time"
+)
+
+// waitReset returns the next ResetCompleted event, skipping other events.
+func waitReset(t *testing.T, events <-chan Event, timeout time.Duration) (ResetCompleted, bool) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e := <-events:
+			if done, ok := e.(ResetCompleted); ok {
+				return done, true
+			}
+		case <-deadline:
+			return ResetCompleted{}, false
+		}
+	}
+}
+
+func TestDeviceResetRequests(t *testing.T) {
+	sim := NewSimDriver(SimConfig{InputChannels: 4, OutputChannels: 4})
+
+	device := Device{ResetDebounce: 20 * time.Millisecond}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.OpenWith(OpenOptions{InputChannels: []int{2}, OutputChannels: []int{1, 3}, BufferSize: 128}); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	events, cancel := device.Subscribe()
+	defer cancel()
+
+	calls := 0
+	if err := device.Start(func(in, out [][]int32) {
+		if len(in) != 1 || len(out) != 2 || len(in[0]) != 128 {
+			t.Errorf("handler got %d inputs and %d outputs", len(in), len(out))
+		}
+		calls++
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a burst of requests, answered from the callback without resetting there
+	for range 3 {
+		if sim.SendMessage(kAsioResetRequest, 0) != 1 {
+			t.Fatal("reset request refused")
+		}
+	}
+	sim.SendMessage(kAsioResyncRequest, 0)
+
+	done, ok := waitReset(t, events, time.Second)
+	if !ok {
+		t.Fatal("no reset completed")
+	}
+	if done.Err != nil {
+		t.Fatal(done.Err)
+	}
+	if _, ok := waitReset(t, events, 100*time.Millisecond); ok {
+		t.Error("burst of requests reset the device more than once")
+	}
+
+	if !sim.Running() {
+		t.Error("driver not restarted")
+	}
+	if in := device.InputChannels(); len(in) != 1 || in[0].Channel != 2 {
+		t.Errorf("inputs after reset: %+v", in)
+	}
+	if out := device.OutputChannels(); len(out) != 2 || out[0].Channel != 1 || out[1].Channel != 3 {
+		t.Errorf("outputs after reset: %+v", out)
+	}
+	sim.Step(2)
+	if calls != 2 {
+		t.Errorf("handler called %d times after reset, want 2", calls)
+	}
+}
+
+func TestDeviceResetBufferSizeChange(t *testing.T) {
+	sim := NewSimDriver(SimConfig{})
+
+	device := Device{ResetDebounce: time.Millisecond}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	events, cancel := device.Subscribe()
+	defer cancel()
+
+	sim.SendMessage(kAsioBufferSizeChange, 512)
+	done, ok := waitReset(t, events, time.Second)
+	if !ok || done.Err != nil {
+		t.Fatalf("reset: %v, %v", ok, done.Err)
+	}
+	if size := device.BufferSize(); size != 512 {
+		t.Errorf("buffer size %d after change, want 512", size)
+	}
+	if sim.Running() {
+		t.Error("stopped driver started by reset")
+	}
+
+	// a manual reset keeps the requested size
+	if err := device.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if size := device.BufferSize(); size != 512 {
+		t.Errorf("buffer size %d after Reset, want 512", size)
+	}
+	if out := device.OutputChannels(); len(out) != 2 {
+		t.Errorf("outputs after Reset: %+v", out)
+	}
+}
+
+func TestDeviceResetReload(t *testing.T) {
+	const name = "Reset Reload Sim"
+	sims := make(chan *SimDriver, 2)
+	RegisterDriver(name, func() (Driver, error) {
+		sim := NewSimDriver(SimConfig{Name: name, InputChannels: 2, OutputChannels: 2})
+		sims <- sim
+		return sim, nil
+	})
+	defer RegisterDriver(name, nil)
+
+	device := Device{ResetDebounce: time.Millisecond}
+	if err := device.Load(name); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.SetSampleRate(48000); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := device.Subscribe()
+	defer cancel()
+	calls := 0
+	if err := device.Start(func(in, out [][]int32) { calls++ }); err != nil {
+		t.Fatal(err)
+	}
+
+	// the driver loaded by name is released and instantiated again
+	first := <-sims
+	first.SendMessage(kAsioResetRequest, 0)
+	if done, ok := waitReset(t, events, time.Second); !ok || done.Err != nil {
+		t.Fatalf("reinit: %v, %v", ok, done.Err)
+	}
+	var second *SimDriver
+	select {
+	case second = <-sims:
+	default:
+		t.Fatal("driver not instantiated again")
+	}
+	if device.State() != StateRunning || !second.Running() || first.Running() {
+		t.Errorf("state %v after reinit, new driver running %v", device.State(), second.Running())
+	}
+	if rate, _ := second.GetSampleRate(); rate != 48000 {
+		t.Errorf("sample rate %v after reinit, want 48000", rate)
+	}
+	first.Step(1)
+	second.Step(2)
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestDeviceCallsFromHandler(t *testing.T) {
+	sim := NewSimDriver(SimConfig{InputChannels: 2, OutputChannels: 2, PreferredSize: 64, RealTime: true})
+	device := Device{ResetDebounce: time.Millisecond}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := device.Subscribe()
+	defer cancel()
+
+	// the handler calls the device while it is stopped, closed or reset
+	calls := make(chan struct{}, 1)
+	handler := func(in, out [][]int32) {
+		select {
+		case calls <- struct{}{}:
+		default:
+		}
+		time.Sleep(200 * time.Microsecond)
+		device.GetSamplePosition()
+		device.BufferSize()
+		device.InputChannels()
+		device.OutputChannels()
+		device.State()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 5 {
+			if err := device.Start(handler); err != nil {
+				t.Error(err)
+				return
+			}
+			<-calls
+			if err := device.Stop(); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := device.Start(nil); err != nil {
+				t.Error(err)
+				return
+			}
+			<-calls
+			sim.SendMessage(kAsioResetRequest, 0)
+			if done, ok := waitReset(t, events, time.Second); !ok || done.Err != nil {
+				t.Errorf("reset %d: %v, %v", i, ok, done.Err)
+				return
+			}
+			<-calls
+			if err := device.Stop(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if err := device.Close(); err != nil {
+			t.Error(err)
+		}
+		device.Unload()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock between the handler and the device")
+	}
+}
+
+func TestDeviceAccessorsDuringReset(t *testing.T) {
+	sim := NewSimDriver(SimConfig{InputChannels: 2, OutputChannels: 2})
+
+	device := Device{ResetDebounce: time.Millisecond}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := device.Subscribe()
+	defer cancel()
+
+	// the accessors are read while the supervisor recreates the buffers
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			device.BufferSize()
+			if in := device.InputChannels(); len(in) != 0 && len(in) != 2 {
+				t.Errorf("%d input channels", len(in))
+			}
+			device.OutputChannels()
+		}
+	}()
+	for i := range 5 {
+		sim.SendMessage(kAsioBufferSizeChange, int32(128<<(i%2)))
+		if done, ok := waitReset(t, events, time.Second); !ok || done.Err != nil {
+			t.Fatalf("reset %d: %v, %v", i, ok, done.Err)
+		}
+		sim.SendMessage(kAsioResetRequest, 0)
+		if done, ok := waitReset(t, events, time.Second); !ok || done.Err != nil {
+			t.Fatalf("reinit %d: %v, %v", i, ok, done.Err)
+		}
+	}
+	close(stop)
+	<-done
+	if size := device.BufferSize(); size != 128 {
+		t.Errorf("buffer size %d after resets, want 128", size)
+	}
+}
@@ -0,0 +1,289 @@
+package asio
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// RingBuffer is a multichannel ring buffer moving frames between one producer
+// and one consumer, such as the driver's callback and a goroutine writing to
+// disk. Read and Write never block nor allocate, and may be called from a
+// handler; ReadWait and WriteWait block until all the frames are moved.
+//
+// At most one goroutine may write and one read at a time. The buffers of all
+// channels passed in one call must have the same length.
+type RingBuffer[T int32 | float32] struct {
+	buf  [][]T
+	size uint64
+
+	read  atomic.Uint64 // frames read, only advanced by the consumer
+	write atomic.Uint64 // frames written, only advanced by the producer
+
+	overruns  atomic.Uint64
+	underruns atomic.Uint64
+
+	// a waiting side sets its flag before checking the buffer again, then
+	// waits for the other side to signal it
+	readerWaiting atomic.Bool
+	writerWaiting atomic.Bool
+	readable      chan struct{}
+	writable      chan struct{}
+
+	closed atomic.Bool
+	done   chan struct{}
+}
+
+// NewRingBuffer returns a ring buffer holding up to frames frames of the given number of channels.
+// It panics if either is not positive.
+func NewRingBuffer[T int32 | float32](channels, frames int) *RingBuffer[T] {
+	if channels <= 0 || frames <= 0 {
+		panic("asio: NewRingBuffer with non-positive channels or frames")
+	}
+	r := &RingBuffer[T]{
+		buf:      make([][]T, channels),
+		size:     uint64(frames),
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	for i := range r.buf {
+		r.buf[i] = make([]T, frames)
+	}
+	return r
+}
+
+// Channels returns the number of channels.
+func (r *RingBuffer[T]) Channels() int {
+	return len(r.buf)
+}
+
+// Cap returns the capacity in frames.
+func (r *RingBuffer[T]) Cap() int {
+	return int(r.size)
+}
+
+// Len returns the number of frames ready to be read.
+func (r *RingBuffer[T]) Len() int {
+	return int(r.write.Load() - r.read.Load())
+}
+
+// Free returns the number of frames that can be written without overrun.
+func (r *RingBuffer[T]) Free() int {
+	return int(r.size - (r.write.Load() - r.read.Load()))
+}
+
+// Overruns returns the number of frames dropped by Write because the buffer was full.
+func (r *RingBuffer[T]) Overruns() uint64 {
+	return r.overruns.Load()
+}
+
+// Underruns returns the number of frames missing from Read because the buffer was empty.
+func (r *RingBuffer[T]) Underruns() uint64 {
+	return r.underruns.Load()
+}
+
+// numFrames returns the number of frames of buffers.
+func numFrames[T any](bufs [][]T) int {
+	if len(bufs) == 0 {
+		return 0
+	}
+	return len(bufs[0])
+}
+
+// Write writes as many frames of src as fit, and counts the others as overruns.
+// Channels of src beyond the buffer's are ignored, missing ones are written as silence.
+func (r *RingBuffer[T]) Write(src [][]T) int {
+	want := numFrames(src)
+	n := r.writeAt(src, 0, want)
+	if n < want {
+		r.overruns.Add(uint64(want - n))
+	}
+	return n
+}
+
+// Read reads as many frames into dst as are available, and counts the missing ones as underruns.
+// Channels of dst beyond the buffer's are zeroed.
+func (r *RingBuffer[T]) Read(dst [][]T) int {
+	want := numFrames(dst)
+	n := r.readAt(dst, 0, want)
+	if n < want {
+		r.underruns.Add(uint64(want - n))
+	}
+	return n
+}
+
+// WriteWait writes all the frames of src, waiting for space to free up.
+// It returns early with ctx.Err() when ctx is done, or io.ErrClosedPipe if the buffer is closed.
+func (r *RingBuffer[T]) WriteWait(ctx context.Context, src [][]T) (int, error) {
+	want := numFrames(src)
+	n := 0
+	for {
+		if r.closed.Load() {
+			return n, io.ErrClosedPipe
+		}
+		n += r.writeAt(src, n, want-n)
+		if n == want {
+			return n, nil
+		}
+		if err := r.wait(ctx, &r.writerWaiting, r.writable, func() bool { return r.Free() > 0 }); err != nil {
+			return n, err
+		}
+	}
+}
+
+// ReadWait reads frames into dst until it is full, waiting for them to be written.
+// It returns early with ctx.Err() when ctx is done, or io.EOF once the buffer is
+// closed and every frame written before was read.
+func (r *RingBuffer[T]) ReadWait(ctx context.Context, dst [][]T) (int, error) {
+	want := numFrames(dst)
+	n := 0
+	for {
+		// frames written before Close are still read
+		closed := r.closed.Load()
+		n += r.readAt(dst, n, want-n)
+		if n == want {
+			return n, nil
+		}
+		if closed {
+			return n, io.EOF
+		}
+		if err := r.wait(ctx, &r.readerWaiting, r.readable, func() bool { return r.Len() > 0 }); err != nil {
+			return n, err
+		}
+	}
+}
+
+// wait blocks until ready returns true, signal is received, the buffer is closed or ctx is done.
+func (r *RingBuffer[T]) wait(ctx context.Context, waiting *atomic.Bool, signal <-chan struct{}, ready func() bool) error {
+	waiting.Store(true)
+	defer waiting.Store(false)
+	// the other side may have moved frames before it could see the flag
+	if ready() || r.closed.Load() {
+		return nil
+	}
+	select {
+	case <-signal:
+		return nil
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify wakes the other side if it is waiting, without blocking.
+func notify(waiting *atomic.Bool, signal chan<- struct{}) {
+	if waiting.Load() {
+		select {
+		case signal <- struct{}{}:
+		default: // already signalled
+		}
+	}
+}
+
+// Close wakes the waiting sides: WriteWait fails from then on, and ReadWait
+// returns io.EOF once the remaining frames are read. Read and Write still work.
+func (r *RingBuffer[T]) Close() {
+	if r.closed.CompareAndSwap(false, true) {
+		close(r.done)
+	}
+}
+
+// writeAt writes up to frames frames of src from offset, and returns how many were written.
+func (r *RingBuffer[T]) writeAt(src [][]T, offset, frames int) int {
+	w := r.write.Load()
+	n := min(uint64(frames), r.size-(w-r.read.Load()))
+	if n == 0 {
+		return 0
+	}
+	start := w % r.size
+	first := min(n, r.size-start)
+	for c, buf := range r.buf {
+		if c < len(src) {
+			s := src[c][offset:]
+			copy(buf[start:start+first], s)
+			copy(buf[:n-first], s[first:])
+		} else {
+			clear(buf[start : start+first])
+			clear(buf[:n-first])
+		}
+	}
+	r.write.Store(w + n)
+	notify(&r.readerWaiting, r.readable)
+	return int(n)
+}
+
+// readAt reads up to frames frames into dst from offset, and returns how many were read.
+func (r *RingBuffer[T]) readAt(dst [][]T, offset, frames int) int {
+	rd := r.read.Load()
+	n := min(uint64(frames), r.write.Load()-rd)
+	if n == 0 {
+		return 0
+	}
+	start := rd % r.size
+	first := min(n, r.size-start)
+	for c, d := range dst {
+		d = d[offset : offset+int(n)]
+		if c < len(r.buf) {
+			copy(d, r.buf[c][start:start+first])
+			copy(d[first:], r.buf[c][:n-first])
+		} else {
+			clear(d)
+		}
+	}
+	r.read.Store(rd + n)
+	notify(&r.writerWaiting, r.writable)
+	return int(n)
+}
+
+// RingHandler returns a handler for Device.Start or Device.StartFloat writing
+// the input buffers to capture and reading the output buffers from playback;
+// either may be nil. Output frames missing from playback are silent.
+//
+// The handler only moves frames, so that the goroutines on the other side of
+// the ring buffers can do slow work without causing dropouts.
+func RingHandler[T int32 | float32](capture, playback *RingBuffer[T]) func(in, out [][]T) {
+	return func(in, out [][]T) {
+		if capture != nil {
+			capture.Write(in)
+		}
+		n := 0
+		if playback != nil {
+			n = playback.Read(out)
+		}
+		for _, buf := range out {
+			clear(buf[n:])
+		}
+	}
+}
+
+// sampleRing is a RingBuffer of either sample type, for the queues of streams
+// and recorders whose sample type depends on the device or the file format.
+type sampleRing interface {
+	Channels() int
+	Cap() int
+	Len() int
+	Free() int
+	Overruns() uint64
+	Underruns() uint64
+	Close()
+}
+
+// resizeBufs returns channels buffers of n samples, reusing those of bufs
+// that are large enough, so that a handler converting samples allocates on
+// its first call at most.
+func resizeBufs[T any](bufs [][]T, channels, n int) [][]T {
+	if cap(bufs) < channels {
+		bufs = append(bufs[:cap(bufs)], make([][]T, channels-cap(bufs))...)
+	}
+	bufs = bufs[:channels]
+	for i, buf := range bufs {
+		if cap(buf) < n {
+			bufs[i] = make([]T, n)
+		} else {
+			bufs[i] = buf[:n]
+		}
+	}
+	return bufs
+}
@@ -0,0 +1,227 @@
+package asio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := NewRingBuffer[int32](2, 5)
+	if r.Channels() != 2 || r.Cap() != 5 || r.Len() != 0 || r.Free() != 5 {
+		t.Fatalf("new ring buffer: %d channels, cap %d, len %d, free %d", r.Channels(), r.Cap(), r.Len(), r.Free())
+	}
+
+	if n := r.Write([][]int32{{1, 2, 3}, {-1, -2, -3}}); n != 3 {
+		t.Fatalf("wrote %d frames, want 3", n)
+	}
+	dst := [][]int32{make([]int32, 2), make([]int32, 2)}
+	if n := r.Read(dst); n != 2 || !slices.Equal(dst[0], []int32{1, 2}) || !slices.Equal(dst[1], []int32{-1, -2}) {
+		t.Fatalf("read %d frames %v", n, dst)
+	}
+
+	// wraps around, drops what does not fit, and writes missing channels as silence
+	if n := r.Write([][]int32{{4, 5, 6, 7, 8}}); n != 4 || r.Overruns() != 1 || r.Free() != 0 {
+		t.Fatalf("wrote %d frames, %d overruns, %d free", n, r.Overruns(), r.Free())
+	}
+	dst = [][]int32{make([]int32, 7), make([]int32, 7), {9, 9, 9, 9, 9, 9, 9}}
+	if n := r.Read(dst); n != 5 || r.Underruns() != 2 {
+		t.Fatalf("read %d frames, %d underruns", n, r.Underruns())
+	}
+	want := [][]int32{{3, 4, 5, 6, 7, 0, 0}, {-3, 0, 0, 0, 0, 0, 0}, {0, 0, 0, 0, 0, 9, 9}}
+	for c := range want {
+		if !slices.Equal(dst[c], want[c]) {
+			t.Errorf("channel %d: read %v, want %v", c, dst[c], want[c])
+		}
+	}
+
+	r.Write([][]int32{{10}, {-10}})
+	r.Close()
+	if _, err := r.WriteWait(context.Background(), [][]int32{{11}, {-11}}); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("WriteWait after Close: %v", err)
+	}
+	dst = [][]int32{make([]int32, 2), make([]int32, 2)}
+	if n, err := r.ReadWait(context.Background(), dst); n != 1 || err != io.EOF || dst[0][0] != 10 {
+		t.Errorf("ReadWait after Close = %d, %v, read %v", n, err, dst)
+	}
+}
+
+func TestRingBufferWait(t *testing.T) {
+	r := NewRingBuffer[float32](1, 4)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if n, err := r.ReadWait(ctx, [][]float32{make([]float32, 1)}); n != 0 || err != context.DeadlineExceeded {
+		t.Errorf("ReadWait of an empty buffer = %d, %v", n, err)
+	}
+
+	// a writer waiting for a full buffer is woken by Close
+	r.Write([][]float32{make([]float32, 4)})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Close()
+	}()
+	if n, err := r.WriteWait(context.Background(), [][]float32{{1}}); n != 0 || !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("WriteWait to a full buffer = %d, %v", n, err)
+	}
+}
+
+func TestRingBufferConcurrent(t *testing.T) {
+	const total = 200_000
+	r := NewRingBuffer[int32](2, 100)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer r.Close()
+		rnd := rand.New(rand.NewSource(1))
+		buf := [][]int32{make([]int32, 300), make([]int32, 300)}
+		for next := 0; next < total; {
+			n := min(total-next, 1+rnd.Intn(300))
+			for i := range n {
+				buf[0][i], buf[1][i] = int32(next+i), -int32(next+i)
+			}
+			if _, err := r.WriteWait(ctx, [][]int32{buf[0][:n], buf[1][:n]}); err != nil {
+				t.Error(err)
+				return
+			}
+			next += n
+		}
+	}()
+
+	rnd := rand.New(rand.NewSource(2))
+	buf := [][]int32{make([]int32, 300), make([]int32, 300)}
+	next := 0
+	for {
+		m := 1 + rnd.Intn(300)
+		n, err := r.ReadWait(ctx, [][]int32{buf[0][:m], buf[1][:m]})
+		for i := range n {
+			if buf[0][i] != int32(next) || buf[1][i] != -int32(next) {
+				t.Fatalf("frame %d read as %d, %d", next, buf[0][i], buf[1][i])
+			}
+			next++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if next != total {
+		t.Errorf("read %d frames, want %d", next, total)
+	}
+	if r.Overruns() != 0 || r.Underruns() != 0 {
+		t.Errorf("%d overruns and %d underruns while waiting", r.Overruns(), r.Underruns())
+	}
+}
+
+func TestRingBufferAllocs(t *testing.T) {
+	r := NewRingBuffer[float32](2, 1024)
+	buf := [][]float32{make([]float32, 256), make([]float32, 256)}
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Write(buf)
+		r.Read(buf)
+		r.WriteWait(ctx, buf)
+		r.ReadWait(ctx, buf)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per run", allocs)
+	}
+
+	// waking a blocked reader does not allocate either
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			r.ReadWait(ctx, buf)
+		}
+	}()
+	allocs = testing.AllocsPerRun(99, func() {
+		for r.Free() < 256 {
+			time.Sleep(time.Millisecond)
+		}
+		r.Write(buf)
+	})
+	<-done
+	if allocs != 0 {
+		t.Errorf("%v allocations per wake-up", allocs)
+	}
+}
+
+func TestRingHandler(t *testing.T) {
+	const bufferSize = 64
+	var mu sync.Mutex
+	var sent, played []int32
+	next := int32(0)
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 1,
+		PreferredSize:  bufferSize,
+		OnInput: func(_ int, in [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := 0; i < len(in[0]); i += 4 {
+				next++
+				binary.LittleEndian.PutUint32(in[0][i:], uint32(next))
+				sent = append(sent, next)
+			}
+		},
+		OnOutput: func(_ int, out [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := 0; i < len(out[0]); i += 4 {
+				played = append(played, int32(binary.LittleEndian.Uint32(out[0][i:])))
+			}
+		},
+	})
+
+	var device Device
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	// the recorded input is played back two buffers later
+	capture, playback := NewRingBuffer[int32](1, 4*bufferSize), NewRingBuffer[int32](1, 4*bufferSize)
+	playback.Write([][]int32{make([]int32, 2*bufferSize)})
+	if err := device.Start(RingHandler(capture, playback)); err != nil {
+		t.Fatal(err)
+	}
+	buf := [][]int32{make([]int32, bufferSize)}
+	for range 10 {
+		sim.Step(1)
+		if n, err := capture.ReadWait(context.Background(), buf); n != bufferSize || err != nil {
+			t.Fatal(n, err)
+		}
+		playback.Write(buf)
+	}
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(played[:2*bufferSize], make([]int32, 2*bufferSize)) {
+		t.Errorf("played %v before the input", played[:2*bufferSize])
+	}
+	if !slices.Equal(played[2*bufferSize:], sent[:len(sent)-2*bufferSize]) {
+		t.Errorf("played %v, want %v", played[2*bufferSize:], sent[:len(sent)-2*bufferSize])
+	}
+	if capture.Overruns() != 0 || playback.Underruns() != 0 {
+		t.Errorf("%d overruns and %d underruns", capture.Overruns(), playback.Underruns())
+	}
+}
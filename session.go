@@ -2,62 +2,407 @@ package asio
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"time"
 )
 
+// Session runs a driver with a handler, from loading the driver to unloading it.
+// Unset fields keep the driver's own settings.
 type Session struct {
 	DriverName string
-	SampleRate float64
-	IOHandler  func(in, out [][]int32)
-	WaitFunc   func()
+	// FallbackDriverNames are tried in order when DriverName cannot be loaded.
+	FallbackDriverNames []string
+	Driver              Driver // used instead of looking up DriverName when set
+
+	SampleRate float64 // zero keeps the driver's current rate
+	// ClockSourceName selects a clock source by name; empty keeps the driver's current one.
+	ClockSourceName string
+
+	// Buffers to create, as for Device.OpenWith.
+	BufferSize         int
+	Latency            time.Duration
+	InputChannels      []int
+	OutputChannels     []int
+	InputChannelNames  []string
+	OutputChannelNames []string
+
+	// Exactly one handler must be set: IOHandler receives the driver's
+	// buffers as they are, FloatHandler samples converted to float32.
+	IOHandler    func(in, out [][]int32)
+	FloatHandler func(in, out [][]float32)
+
+	// Linear gains applied to the samples of each selected input before
+	// FloatHandler, and of each output after it; nil leaves them unchanged.
+	InputGains  []float64
+	OutputGains []float64
+
+	WaitFunc func()       // used by Run only
+	Logger   *slog.Logger // receives diagnostics; nil discards them
+	// OnEvent is called with the events of the device, such as ResetRequested
+	// or Overload, from a goroutine of the session.
+	OnEvent func(Event)
+
+	// MaxDuration stops the session after running this long; zero means no limit.
+	MaxDuration time.Duration
+	// StopAfterFrames stops the session after this many frames; zero means no limit.
+	// The last buffer passed to the handler is shortened to end at the limit exactly,
+	// and the rest of its outputs is zeroed. With IOHandler and 16 or 24 bit
+	// samples, it ends up to 3 frames earlier, at a whole int32 of every channel.
+	StopAfterFrames int64
 }
 
-func (s Session) Run() error {
+// Validate checks the fields of the session, reporting every invalid one.
+func (s Session) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
 
-	if s.IOHandler == nil {
-		return fmt.Errorf("IOHandler must be provided")
+	if s.Driver == nil && s.DriverName == "" {
+		invalid("DriverName", "must be set unless Driver is")
+	}
+	for i, name := range s.FallbackDriverNames {
+		if name == "" {
+			invalid(fmt.Sprintf("FallbackDriverNames[%d]", i), "empty name")
+		}
 	}
-	if s.DriverName == "" {
-		s.DriverName = "ASIO4ALL v2"
+	if s.SampleRate < 0 || math.IsNaN(s.SampleRate) || math.IsInf(s.SampleRate, 0) {
+		invalid("SampleRate", "invalid rate %v", s.SampleRate)
+	}
+	if s.BufferSize < 0 {
+		invalid("BufferSize", "must not be negative")
+	}
+	if s.Latency < 0 {
+		invalid("Latency", "must not be negative")
+	}
+	if s.BufferSize != 0 && s.Latency != 0 {
+		invalid("Latency", "cannot be set together with BufferSize")
+	}
+	for _, sel := range []struct {
+		field   string
+		numbers []int
+		names   []string
+	}{
+		{"InputChannels", s.InputChannels, s.InputChannelNames},
+		{"OutputChannels", s.OutputChannels, s.OutputChannelNames},
+	} {
+		if sel.numbers != nil && sel.names != nil {
+			invalid(sel.field, "cannot be set together with %sNames", sel.field)
+		}
+		for _, n := range sel.numbers {
+			if n < 0 {
+				invalid(sel.field, "invalid channel %d", n)
+			}
+		}
+	}
+	switch {
+	case s.IOHandler == nil && s.FloatHandler == nil:
+		invalid("IOHandler", "IOHandler or FloatHandler must be provided")
+	case s.IOHandler != nil && s.FloatHandler != nil:
+		invalid("FloatHandler", "cannot be set together with IOHandler")
+	}
+	for _, g := range []struct {
+		field string
+		gains []float64
+	}{
+		{"InputGains", s.InputGains},
+		{"OutputGains", s.OutputGains},
+	} {
+		if g.gains != nil && s.FloatHandler == nil {
+			invalid(g.field, "only applies to FloatHandler")
+		}
+		for i, gain := range g.gains {
+			if gain < 0 || math.IsNaN(gain) || math.IsInf(gain, 0) {
+				invalid(g.field, "invalid gain %v of channel %d", gain, i)
+			}
+		}
 	}
-	if s.SampleRate == 0 {
-		s.SampleRate = 44100
+	if s.MaxDuration < 0 {
+		invalid("MaxDuration", "must not be negative")
 	}
-	if s.WaitFunc == nil {
-		s.WaitFunc = func() {
+	if s.StopAfterFrames < 0 {
+		invalid("StopAfterFrames", "must not be negative")
+	}
+	return errors.Join(errs...)
+}
+
+// errSessionDone ends a session without an error, when a limit is reached or WaitFunc returns.
+var errSessionDone = errors.New("session done")
+
+// Run runs the session until WaitFunc returns, or until a limit of the session is reached.
+// A WaitFunc still running when the session ends is left to return on its own.
+//
+// Without WaitFunc, Run waits for enter on stdin, unless MaxDuration or
+// StopAfterFrames is set: the read of stdin cannot be cancelled, and would take
+// the next line of input after the session ended. It is still left pending
+// when the session ends because the driver failed.
+func (s Session) Run() error {
+	wait := s.WaitFunc
+	if wait == nil && s.MaxDuration == 0 && s.StopAfterFrames == 0 {
+		wait = func() {
 			fmt.Println("press enter to continue...")
 			bufio.NewReader(os.Stdin).ReadBytes('\n')
 		}
 	}
+	return s.run(context.Background(), wait)
+}
 
-	d := Device{}
+// RunContext runs the session until ctx is done, the driver fails, or a limit
+// of the session is reached. Processing is then stopped and the buffers are
+// disposed before the driver is unloaded.
+//
+// The error joins the reason the session ended, ctx.Err() if ctx is done, with
+// any error of stopping and disposing. Reaching a limit is not an error.
+func (s Session) RunContext(ctx context.Context) error {
+	return s.run(ctx, nil)
+}
 
-	if err := d.Load(s.DriverName); err != nil {
+// run runs the session, calling wait if not nil once processing has started.
+func (s Session) run(ctx context.Context, wait func()) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("session: %w", err)
+		}
+	}()
+
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	d := Device{Logger: s.Logger}
+	if err := s.load(&d); err != nil {
 		return err
 	}
 	defer d.Unload()
 
-	if err := d.SetSampleRate(s.SampleRate); err != nil {
+	if err := s.configure(&d); err != nil {
 		return err
 	}
-	if err := d.Open(); err != nil {
+	if err := d.OpenWith(OpenOptions{
+		InputChannels:      s.InputChannels,
+		OutputChannels:     s.OutputChannels,
+		InputChannelNames:  s.InputChannelNames,
+		OutputChannelNames: s.OutputChannelNames,
+		BufferSize:         s.BufferSize,
+		Latency:            s.Latency,
+	}); err != nil {
 		return err
 	}
 
-	if err := d.Start(s.IOHandler); err != nil {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	// OnEvent is not called once the session has returned
+	events, unsubscribe := d.Subscribe()
+	forwarded := make(chan struct{})
+	defer func() {
+		unsubscribe()
+		<-forwarded
+	}()
+	go func() {
+		defer close(forwarded)
+		for event := range events {
+			if s.OnEvent != nil {
+				s.OnEvent(event)
+			}
+			// a failed reset leaves the driver unusable
+			if done, ok := event.(ResetCompleted); ok && done.Err != nil {
+				stop(fmt.Errorf("driver reset failed: %w", done.Err))
+			}
+		}
+	}()
+
+	if s.FloatHandler != nil {
+		handler := s.FloatHandler
+		if s.InputGains != nil || s.OutputGains != nil {
+			if handler, err = applyGains(handler, s.InputGains, s.OutputGains, len(d.InputChannels()), len(d.OutputChannels())); err != nil {
+				return err
+			}
+		}
+		err = d.StartFloat(limitFrames(handler, s.StopAfterFrames, nil, stop))
+	} else {
+		var sizes []int
+		for _, cinfo := range append(d.InputChannels(), d.OutputChannels()...) {
+			sizes = append(sizes, cinfo.SampleType.Size())
+		}
+		err = d.Start(limitFrames(s.IOHandler, s.StopAfterFrames, sizes, stop))
+	}
+	if err != nil {
 		return err
 	}
 
-	s.WaitFunc()
+	if s.MaxDuration > 0 {
+		timer := time.AfterFunc(s.MaxDuration, func() { stop(errSessionDone) })
+		defer timer.Stop()
+	}
+	if wait != nil {
+		go func() {
+			wait()
+			stop(errSessionDone)
+		}()
+	}
+
+	<-ctx.Done()
+	if cause := context.Cause(ctx); cause != errSessionDone {
+		err = cause
+	}
 
-	if err := d.Stop(); err != nil {
-		return err
+	// after a failed reset there is nothing left to stop or dispose
+	if d.State() == StateRunning {
+		err = errors.Join(err, d.Stop())
+	}
+	if d.State() == StatePrepared {
+		err = errors.Join(err, d.Close())
+	}
+	return err
+}
+
+// load loads Driver, or the first of DriverName and FallbackDriverNames that loads.
+func (s Session) load(d *Device) error {
+	if s.Driver != nil {
+		return d.LoadDriver(s.Driver)
+	}
+
+	var errs []error
+	for _, name := range append([]string{s.DriverName}, s.FallbackDriverNames...) {
+		err := d.Load(name)
+		if err == nil {
+			return nil
+		}
+		d.logger().Warn("driver not loaded", "name", name, "err", err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// configure applies the sample rate and clock source of the session to a loaded device.
+func (s Session) configure(d *Device) error {
+	if s.SampleRate != 0 {
+		if err := d.SetSampleRate(s.SampleRate); err != nil {
+			return err
+		}
+	}
+	if s.ClockSourceName == "" {
+		return nil
 	}
 
-	if err := d.Close(); err != nil {
+	sources, err := d.GetClockSources()
+	if err != nil {
 		return err
 	}
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		if source.Name == s.ClockSourceName {
+			return d.SetClockSource(source.Index)
+		}
+		names[i] = source.Name
+	}
+	return fmt.Errorf("clock source %q not found among %q", s.ClockSourceName, names)
+}
 
-	return nil
+// applyGains wraps a handler to apply gains to its inputs and outputs,
+// checking that they match the number of channels.
+func applyGains(handler func(in, out [][]float32), inputGains, outputGains []float64, numInputs, numOutputs int) (func(in, out [][]float32), error) {
+	if inputGains != nil && len(inputGains) != numInputs {
+		return nil, fmt.Errorf("InputGains: %d gains for %d input channels", len(inputGains), numInputs)
+	}
+	if outputGains != nil && len(outputGains) != numOutputs {
+		return nil, fmt.Errorf("OutputGains: %d gains for %d output channels", len(outputGains), numOutputs)
+	}
+	scale := func(bufs [][]float32, gains []float64) {
+		for i, gain := range gains {
+			if gain == 1 {
+				continue
+			}
+			for j := range bufs[i] {
+				bufs[i][j] *= float32(gain)
+			}
+		}
+	}
+	return func(in, out [][]float32) {
+		scale(in, inputGains)
+		handler(in, out)
+		scale(out, outputGains)
+	}, nil
+}
+
+// limitFrames wraps a handler to pass it up to limit frames, calling stop when they are passed.
+// A limit of zero leaves the handler as it is.
+//
+// The frames of each call are counted from the length of the buffers, which
+// changes when the driver is reset to another buffer size. sizes holds the
+// sample size in bytes of each channel, inputs then outputs, for the []int32
+// views of Device.Start; it is nil for []float32 buffers. As the views cannot
+// hold part of an int32, the last call is cut to whole int32 words in every
+// channel: with 16 or 24 bit samples, up to 3 frames short of limit.
+func limitFrames[T int32 | float32](handler func(in, out [][]T), limit int64, sizes []int, stop context.CancelCauseFunc) func(in, out [][]T) {
+	if limit <= 0 {
+		return handler
+	}
+
+	// frames whose samples fill whole words in every channel
+	align := 1
+	for _, size := range sizes {
+		for size*align%4 != 0 {
+			align *= 2
+		}
+	}
+	// words of channel i holding n frames
+	words := func(i, n int) int {
+		if sizes == nil {
+			return n
+		}
+		return n * sizes[i] / 4
+	}
+
+	var frames int64
+	var shortIn, shortOut [][]T
+	return func(in, out [][]T) {
+		remaining := limit - frames
+		if remaining <= 0 {
+			for _, buf := range out {
+				clear(buf)
+			}
+			return
+		}
+
+		var bufferSize int
+		switch {
+		case len(in) > 0 && sizes == nil:
+			bufferSize = len(in[0])
+		case len(in) > 0:
+			bufferSize = len(in[0]) * 4 / sizes[0]
+		case len(out) > 0 && sizes == nil:
+			bufferSize = len(out[0])
+		case len(out) > 0:
+			bufferSize = len(out[0]) * 4 / sizes[len(in)]
+		}
+
+		if remaining >= int64(bufferSize) {
+			handler(in, out)
+			frames += int64(bufferSize)
+		} else {
+			n := int(remaining) / align * align
+			shortIn, shortOut = shortIn[:0], shortOut[:0]
+			for i, buf := range in {
+				shortIn = append(shortIn, buf[:words(i, n)])
+			}
+			for i, buf := range out {
+				shortOut = append(shortOut, buf[:words(len(in)+i, n)])
+			}
+			handler(shortIn, shortOut)
+			for i, buf := range out {
+				clear(buf[words(len(in)+i, n):])
+			}
+			frames = limit
+		}
+		if frames >= limit {
+			stop(errSessionDone)
+		}
+	}
 }
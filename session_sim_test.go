@@ -0,0 +1,326 @@
+package asio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionStopAfterFrames(t *testing.T) {
+	const frames = 1000
+
+	var mu sync.Mutex
+	var processed, played int
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 2,
+		PreferredSize:  64,
+		InputTypes:     []SampleType{ASIOSTInt16LSB},
+		RealTime:       true,
+		OnOutput: func(index int, out [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := 0; i < len(out[0]); i += 4 {
+				if out[0][i] != 0 {
+					played++
+				}
+			}
+		},
+	})
+
+	err := Session{
+		Driver: sim,
+		IOHandler: func(in, out [][]int32) {
+			mu.Lock()
+			defer mu.Unlock()
+			// two 16 bit samples per int32
+			if 2*len(in[0]) != len(out[0]) {
+				t.Errorf("%d input words for %d frames", len(in[0]), len(out[0]))
+			}
+			processed += len(out[0])
+			for _, buf := range out {
+				for i := range buf {
+					buf[i] = 1
+				}
+			}
+		},
+		StopAfterFrames: frames,
+	}.RunContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if processed != frames || played != frames {
+		t.Errorf("processed %d frames and played %d, want %d", processed, played, frames)
+	}
+}
+
+func TestSessionStopAfterFramesResized(t *testing.T) {
+	const frames = 3000
+
+	var mu sync.Mutex
+	var processed int
+	sim := NewSimDriver(SimConfig{PreferredSize: 256, RealTime: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := Session{
+		Driver: sim,
+		FloatHandler: func(in, out [][]float32) {
+			mu.Lock()
+			defer mu.Unlock()
+			if processed == 0 {
+				sim.SendMessage(kAsioBufferSizeChange, 1024)
+			}
+			processed += len(in[0])
+		},
+		StopAfterFrames: frames,
+	}.RunContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if processed != frames {
+		t.Errorf("processed %d frames across the resize, want %d", processed, frames)
+	}
+}
+
+func TestSessionStopAfterFramesPacked(t *testing.T) {
+	var processed, words int
+	sim := NewSimDriver(SimConfig{
+		PreferredSize: 256,
+		InputTypes:    []SampleType{ASIOSTInt24LSB, ASIOSTInt16LSB},
+		RealTime:      true,
+	})
+	err := Session{
+		Driver: sim,
+		IOHandler: func(in, out [][]int32) {
+			processed += len(in[0]) * 4 / 3
+			words += len(in[1])
+			if len(in[0])*4%3 != 0 || len(in[0])*4/3 != len(in[1])*2 || len(out[0]) != len(in[1])*2 {
+				t.Errorf("buffers of %d, %d and %d words do not hold the same frames", len(in[0]), len(in[1]), len(out[0]))
+			}
+		},
+		// cut to whole words of 24 bit samples
+		StopAfterFrames: 1001,
+	}.RunContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != 1000 || words != 500 {
+		t.Errorf("processed %d frames in %d words of 16 bit samples, want 1000 in 500", processed, words)
+	}
+}
+
+func TestSessionRunContext(t *testing.T) {
+	session := Session{
+		Driver:    NewSimDriver(SimConfig{RealTime: true}),
+		IOHandler: func(in, out [][]int32) {},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := session.RunContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunContext with a deadline: %v", err)
+	}
+
+	session.MaxDuration = 20 * time.Millisecond
+	start := time.Now()
+	if err := session.RunContext(context.Background()); err != nil {
+		t.Errorf("RunContext with MaxDuration: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < session.MaxDuration {
+		t.Errorf("stopped after %v", elapsed)
+	}
+
+	session.WaitFunc = func() {}
+	session.MaxDuration = 0
+	if err := session.Run(); err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+// initOnceDriver cannot be reinitialized, like a driver whose hardware was unplugged.
+type initOnceDriver struct {
+	*SimDriver
+	inits *int
+}
+
+func (drv initOnceDriver) Init(sysHandle uintptr) bool {
+	*drv.inits++
+	return *drv.inits == 1 && drv.SimDriver.Init(sysHandle)
+}
+
+func TestSessionFatalReset(t *testing.T) {
+	sim := NewSimDriver(SimConfig{})
+	started := make(chan struct{})
+	var once sync.Once
+
+	done := make(chan error)
+	go func() {
+		done <- Session{
+			Driver: initOnceDriver{sim, new(int)},
+			IOHandler: func(in, out [][]int32) {
+				once.Do(func() { close(started) })
+			},
+		}.RunContext(context.Background())
+	}()
+
+	// wait for processing, stepping the clock by hand
+	for running := true; running; {
+		select {
+		case <-started:
+			running = false
+		case <-time.After(time.Millisecond):
+			sim.Step(1)
+		}
+	}
+	sim.SendMessage(kAsioResetRequest, 0)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInitFailed) {
+			t.Errorf("RunContext after a failed reset: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session still running after a failed reset")
+	}
+}
+
+func TestSessionRunStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	// with a limit, stdin is not read, and keeps the next line for the caller
+	err = Session{
+		Driver:      NewSimDriver(SimConfig{RealTime: true}),
+		IOHandler:   func(in, out [][]int32) {},
+		MaxDuration: 10 * time.Millisecond,
+	}.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("next\n")
+	r.SetReadDeadline(time.Now().Add(time.Second))
+	line := make([]byte, 5)
+	if n, err := io.ReadFull(r, line); err != nil || string(line[:n]) != "next\n" {
+		t.Errorf("read %q from stdin after the session: %v", line[:n], err)
+	}
+}
+
+func TestSessionValidate(t *testing.T) {
+	valid := Session{DriverName: "Card", IOHandler: func(in, out [][]int32) {}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid session: %v", err)
+	}
+
+	err := Session{
+		FallbackDriverNames: []string{""},
+		SampleRate:          -1,
+		BufferSize:          256,
+		Latency:             time.Millisecond,
+		InputChannels:       []int{0, -1},
+		OutputChannels:      []int{0},
+		OutputChannelNames:  []string{"Out 1"},
+		MaxDuration:         -time.Second,
+		StopAfterFrames:     -1,
+	}.Validate()
+	for _, field := range []string{
+		"DriverName", "FallbackDriverNames[0]", "SampleRate", "Latency",
+		"InputChannels", "OutputChannels", "IOHandler", "MaxDuration", "StopAfterFrames",
+	} {
+		if !strings.Contains(err.Error(), field+": ") {
+			t.Errorf("%s not reported: %v", field, err)
+		}
+	}
+
+	err = Session{IOHandler: func(in, out [][]int32) {}, FloatHandler: func(in, out [][]float32) {}}.RunContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "FloatHandler: ") {
+		t.Errorf("RunContext of an invalid session: %v", err)
+	}
+}
+
+func TestSessionConfig(t *testing.T) {
+	sim := NewSimDriver(SimConfig{
+		Name:           "Test Fallback",
+		InputChannels:  4,
+		OutputChannels: 4,
+		ClockSources:   []string{"Internal", "Word Clock"},
+		SampleRates:    []float64{44100, 48000},
+		RealTime:       true,
+	})
+	RegisterDriver("Test Fallback", func() (Driver, error) { return sim, nil })
+	defer RegisterDriver("Test Fallback", nil)
+
+	var mu sync.Mutex
+	var sizes [][2]int
+	var overloads int
+	err := Session{
+		DriverName:          "Missing Card",
+		FallbackDriverNames: []string{"Test Fallback"},
+		SampleRate:          48000,
+		ClockSourceName:     "Word Clock",
+		BufferSize:          128,
+		InputChannels:       []int{1, 3},
+		OutputChannelNames:  []string{"Out 2"},
+		FloatHandler: func(in, out [][]float32) {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(sizes) == 0 {
+				sim.SendMessage(kAsioOverload, 0)
+			}
+			sizes = append(sizes, [2]int{len(in), len(out)})
+			if len(in[0]) != 128 {
+				t.Errorf("buffer of %d samples, want 128", len(in[0]))
+			}
+		},
+		OnEvent: func(event Event) {
+			if _, ok := event.(Overload); ok {
+				overloads++
+			}
+		},
+		StopAfterFrames: 512,
+	}.RunContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sizes) != 4 || sizes[0] != [2]int{2, 1} {
+		t.Errorf("handler called with %v channels, want 4 calls with 2 inputs and 1 output", sizes)
+	}
+	if overloads != 1 {
+		t.Errorf("OnEvent received %d overloads, want 1", overloads)
+	}
+	if rate, _ := sim.GetSampleRate(); rate != 48000 {
+		t.Errorf("sample rate %v, want 48000", rate)
+	}
+	if sources, _ := sim.GetClockSources(); !sources[1].IsCurrentSource {
+		t.Errorf("clock sources %+v, want Word Clock selected", sources)
+	}
+
+	err = Session{
+		DriverName:      "Test Fallback",
+		ClockSourceName: "S/PDIF",
+		IOHandler:       func(in, out [][]int32) {},
+	}.RunContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Word Clock") {
+		t.Errorf("unknown clock source: %v", err)
+	}
+}
@@ -0,0 +1,750 @@
+package asio
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// SimConfig describes the hardware emulated by a SimDriver.
+// Zero fields take the defaults noted next to them.
+type SimConfig struct {
+	Name    string // "ASIO Simulator"
+	Version int32  // 1
+
+	InputChannels  int // 2
+	OutputChannels int // 2
+
+	// SampleType is used for every channel without an entry in InputTypes/OutputTypes.
+	SampleType  SampleType // ASIOSTInt32LSB
+	InputTypes  []SampleType
+	OutputTypes []SampleType
+
+	// Channel names; missing entries are named "In N" and "Out N".
+	InputNames  []string
+	OutputNames []string
+
+	MinSize       int // 64
+	MaxSize       int // 2048
+	PreferredSize int // 256
+	Granularity   int // -1 (powers of two)
+
+	InputLatency  int // buffer size
+	OutputLatency int // buffer size
+
+	// Supported sample rates; the first one is the initial rate.
+	SampleRates []float64 // 44100, 48000, 88200, 96000
+
+	// Names of the clock sources; the first one is selected initially.
+	ClockSources []string // "Internal"
+
+	// DSD lets the host switch the driver to DSD with the SetIoFormat future
+	// call. In DSD mode every channel is ASIOSTDSDInt8MSB1 at 64 × 44.1kHz.
+	DSD bool
+
+	// TimeCode makes the driver report a running time code, equal to the sample position.
+	TimeCode bool
+
+	// RealTime makes the driver advance its clock from a wall-clock ticker while running,
+	// like real hardware. Otherwise the clock only moves when Step is called.
+	RealTime bool
+
+	// OnInput is called with the input half of the double buffer before it is handed to the host.
+	// in is indexed by channel number; channels without buffers are nil.
+	OnInput func(doubleBufferIndex int, in [][]byte)
+	// OnOutput is called with the output half of the double buffer after the host has processed it.
+	// out is indexed by channel number; channels without buffers are nil.
+	OnOutput func(doubleBufferIndex int, out [][]byte)
+}
+
+func (cfg *SimConfig) setDefaults() {
+	if cfg.Name == "" {
+		cfg.Name = "ASIO Simulator"
+	}
+	if cfg.Version == 0 {
+		cfg.Version = 1
+	}
+	if cfg.InputChannels == 0 && cfg.OutputChannels == 0 {
+		cfg.InputChannels, cfg.OutputChannels = 2, 2
+	}
+	if cfg.SampleType == 0 {
+		// NOTE: ASIOSTInt16MSB is 0 and cannot be selected as the default type; use InputTypes/OutputTypes.
+		cfg.SampleType = ASIOSTInt32LSB
+	}
+	if cfg.MinSize == 0 {
+		cfg.MinSize = 64
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = 2048
+	}
+	if cfg.PreferredSize == 0 {
+		cfg.PreferredSize = 256
+	}
+	if cfg.Granularity == 0 && cfg.MinSize != cfg.MaxSize {
+		cfg.Granularity = -1
+	}
+	if len(cfg.SampleRates) == 0 {
+		cfg.SampleRates = []float64{44100, 48000, 88200, 96000}
+	}
+	if len(cfg.ClockSources) == 0 {
+		cfg.ClockSources = []string{"Internal"}
+	}
+}
+
+// simChannel holds the double buffer of one channel.
+type simChannel struct {
+	isInput bool
+	channel int
+	buffers [2][]byte
+}
+
+// SimDriver is a pure-Go Driver emulating an ASIO device.
+//
+// Its clock is virtual: every call to Step processes one buffer period and
+// invokes the host's bufferSwitch callback with alternating buffer halves,
+// which makes runs reproducible. Set SimConfig.RealTime to have the clock
+// advance on its own.
+//
+// The simulator is not listed by ListDrivers unless registered, such as for
+// hosts running without ASIO hardware:
+//
+//	asio.RegisterDriver("ASIO Simulator", func() (asio.Driver, error) {
+//		return asio.NewSimDriver(asio.SimConfig{RealTime: true}), nil
+//	})
+type SimDriver struct {
+	cfg SimConfig
+
+	mu           sync.Mutex
+	initialized  bool
+	sampleRate   float64
+	bufferSize   int
+	channels     []*simChannel
+	slot         int  // callback slot, held while buffers exist
+	timeInfo     bool // the host handles bufferSwitchTimeInfo
+	running      bool
+	index        int   // next double buffer half
+	position     int64 // sample position
+	systemTime   int64 // virtual clock in nanoseconds
+	rateChanged  bool  // to be flagged in the next TimeInfo
+	clockSource  int
+	clockChanged bool // to be flagged in the next TimeInfo
+	ioFormat     IoFormat
+	lastError    string
+	ticker       *simTicker // of the last Start with SimConfig.RealTime
+}
+
+// simTicker is the goroutine advancing the clock of a RealTime driver.
+type simTicker struct {
+	quit       chan struct{} // closed by Stop
+	done       chan struct{} // closed when the goroutine returns
+	inCallback atomic.Bool   // set while the goroutine runs the callbacks of a buffer
+}
+
+// wait waits for the ticker to return, unless called from a callback of the
+// ticker itself, which returns after the callback.
+func (t *simTicker) wait() {
+	if t == nil || t.inCallback.Load() && calledFromTicker() {
+		return
+	}
+	<-t.done
+}
+
+// simTickEntry is the entry of the function of the ticker goroutine.
+var simTickEntry = runtime.FuncForPC(reflect.ValueOf((*SimDriver).tick).Pointer()).Entry()
+
+// calledFromTicker tells whether the caller runs on a ticker goroutine, from
+// the program counters of its frames, without allocating.
+func calledFromTicker() bool {
+	var pcs [32]uintptr
+	for skip := 2; ; skip += len(pcs) {
+		n := runtime.Callers(skip, pcs[:])
+		for _, pc := range pcs[:n] {
+			if f := runtime.FuncForPC(pc); f != nil && f.Entry() == simTickEntry {
+				return true
+			}
+		}
+		if n < len(pcs) {
+			return false
+		}
+	}
+}
+
+var (
+	_ Driver      = (*SimDriver)(nil)
+	_ futureCoder = (*SimDriver)(nil)
+)
+
+// NewSimDriver creates a simulated driver for the given configuration.
+func NewSimDriver(cfg SimConfig) *SimDriver {
+	cfg.setDefaults()
+	return &SimDriver{
+		cfg:        cfg,
+		sampleRate: cfg.SampleRates[0],
+	}
+}
+
+// fail records the message returned by GetErrorMessage and returns err as the driver call op failing.
+func (sim *SimDriver) fail(op string, err *Error, format string, args ...any) error {
+	sim.lastError = fmt.Sprintf(format, args...)
+	return newError(err.Code(), op, sim.cfg.Name, sim.lastError)
+}
+
+func (sim *SimDriver) channelType(channel int, isInput bool) SampleType {
+	if sim.ioFormat == IoFormatDSD {
+		return ASIOSTDSDInt8MSB1
+	}
+	types := sim.cfg.OutputTypes
+	if isInput {
+		types = sim.cfg.InputTypes
+	}
+	if channel < len(types) {
+		return types[channel]
+	}
+	return sim.cfg.SampleType
+}
+
+func (sim *SimDriver) channelName(channel int, isInput bool) string {
+	if isInput {
+		if channel < len(sim.cfg.InputNames) {
+			return sim.cfg.InputNames[channel]
+		}
+		return fmt.Sprintf("In %d", channel+1)
+	}
+	if channel < len(sim.cfg.OutputNames) {
+		return sim.cfg.OutputNames[channel]
+	}
+	return fmt.Sprintf("Out %d", channel+1)
+}
+
+func (sim *SimDriver) numChannels(isInput bool) int {
+	if isInput {
+		return sim.cfg.InputChannels
+	}
+	return sim.cfg.OutputChannels
+}
+
+func (sim *SimDriver) Init(sysHandle uintptr) bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	sim.initialized = true
+	return true
+}
+
+func (sim *SimDriver) GetDriverName() string {
+	return sim.cfg.Name
+}
+
+func (sim *SimDriver) GetDriverVersion() int32 {
+	return sim.cfg.Version
+}
+
+func (sim *SimDriver) GetErrorMessage() string {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return sim.lastError
+}
+
+func (sim *SimDriver) Start() error {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if sim.channels == nil {
+		return sim.fail("Start", ErrorInvalidMode, "no buffers created")
+	}
+	if sim.running {
+		return nil
+	}
+	sim.running = true
+
+	if sim.cfg.RealTime {
+		sim.ticker = &simTicker{quit: make(chan struct{}), done: make(chan struct{})}
+		go sim.tick(sim.ticker, time.Duration(float64(sim.bufferSize)/sim.sampleRate*float64(time.Second)))
+	}
+	return nil
+}
+
+// Stop waits for the callback in progress to return, like real drivers do,
+// unless it is called from that callback.
+func (sim *SimDriver) Stop() error {
+	sim.mu.Lock()
+	if !sim.running {
+		sim.mu.Unlock()
+		return nil
+	}
+	sim.running = false
+	t := sim.ticker
+	if t != nil {
+		close(t.quit)
+	}
+	sim.mu.Unlock()
+
+	t.wait()
+	return nil
+}
+
+func (sim *SimDriver) tick(t *simTicker, period time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.quit:
+			return
+		case <-ticker.C:
+			t.inCallback.Store(true)
+			sim.step()
+			t.inCallback.Store(false)
+		}
+	}
+}
+
+func (sim *SimDriver) GetChannels() (numInputChannels, numOutputChannels int, err error) {
+	return sim.cfg.InputChannels, sim.cfg.OutputChannels, nil
+}
+
+func (sim *SimDriver) GetLatencies() (inputLatency, outputLatency int, err error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	inputLatency, outputLatency = sim.cfg.InputLatency, sim.cfg.OutputLatency
+	if inputLatency == 0 {
+		inputLatency = sim.bufferSize
+	}
+	if outputLatency == 0 {
+		outputLatency = sim.bufferSize
+	}
+	return inputLatency, outputLatency, nil
+}
+
+func (sim *SimDriver) GetBufferSize() (minSize, maxSize, preferredSize, granularity int, err error) {
+	return sim.cfg.MinSize, sim.cfg.MaxSize, sim.cfg.PreferredSize, sim.cfg.Granularity, nil
+}
+
+// dsdSampleRate is the sample rate of a SimDriver in DSD mode, that of DSD64.
+const dsdSampleRate = 64 * 44100
+
+func (sim *SimDriver) CanSampleRate(sampleRate float64) error {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if !sim.supportsRate(sampleRate) {
+		return sim.fail("CanSampleRate", ErrorNoClock, "sample rate %v not supported", sampleRate)
+	}
+	return nil
+}
+
+// supportsRate tells whether the driver can run at a sample rate in its current format. sim.mu must be held.
+func (sim *SimDriver) supportsRate(sampleRate float64) bool {
+	if sim.ioFormat == IoFormatDSD {
+		return sampleRate == dsdSampleRate
+	}
+	return slices.Contains(sim.cfg.SampleRates, sampleRate)
+}
+
+func (sim *SimDriver) GetSampleRate() (sampleRate float64, err error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return sim.sampleRate, nil
+}
+
+func (sim *SimDriver) SetSampleRate(sampleRate float64) error {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if !sim.supportsRate(sampleRate) {
+		return sim.fail("SetSampleRate", ErrorNoClock, "sample rate %v not supported", sampleRate)
+	}
+	if sampleRate != sim.sampleRate {
+		sim.sampleRate = sampleRate
+		sim.rateChanged = true
+	}
+	return nil
+}
+
+func (sim *SimDriver) GetClockSources() ([]ClockSource, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	sources := make([]ClockSource, len(sim.cfg.ClockSources))
+	for i, name := range sim.cfg.ClockSources {
+		sources[i] = ClockSource{
+			Index:             i,
+			AssociatedChannel: -1,
+			AssociatedGroup:   -1,
+			IsCurrentSource:   i == sim.clockSource,
+			Name:              name,
+		}
+	}
+	return sources, nil
+}
+
+func (sim *SimDriver) SetClockSource(index int) error {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if index < 0 || index >= len(sim.cfg.ClockSources) {
+		return sim.fail("SetClockSource", ErrorInvalidMode, "clock source %d does not exist", index)
+	}
+	if index != sim.clockSource {
+		sim.clockSource = index
+		sim.clockChanged = true
+	}
+	return nil
+}
+
+// GetSamplePosition returns the position of the next buffer and the virtual clock in nanoseconds.
+func (sim *SimDriver) GetSamplePosition() (samplePosition, timeStamp int64, err error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if !sim.running {
+		return 0, 0, sim.fail("GetSamplePosition", ErrorSPNotAdvancing, "not running")
+	}
+	return sim.position, sim.systemTime, nil
+}
+
+func (sim *SimDriver) GetChannelInfo(channel int, isInput bool) (*ChannelInfo, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if channel < 0 || channel >= sim.numChannels(isInput) {
+		return nil, sim.fail("GetChannelInfo", ErrorInvalidParameter, "channel %d does not exist", channel)
+	}
+
+	active := slices.ContainsFunc(sim.channels, func(c *simChannel) bool {
+		return c.isInput == isInput && c.channel == channel
+	})
+
+	return &ChannelInfo{
+		Channel:      channel,
+		IsInput:      isInput,
+		IsActive:     active,
+		ChannelGroup: 0,
+		SampleType:   sim.channelType(channel, isInput),
+		Name:         sim.channelName(channel, isInput),
+	}, nil
+}
+
+func (sim *SimDriver) validBufferSize(size int) bool {
+	minSize, maxSize, granularity := sim.cfg.MinSize, sim.cfg.MaxSize, sim.cfg.Granularity
+	if size < minSize || size > maxSize {
+		return false
+	}
+	switch {
+	case granularity == -1:
+		return size&(size-1) == 0
+	case granularity > 0:
+		return (size-minSize)%granularity == 0
+	}
+	return size == sim.cfg.PreferredSize
+}
+
+// allocBuffer returns n zeroed bytes with 8 byte alignment, suitable for any sample type.
+func allocBuffer(n int) []byte {
+	words := make([]uint64, (n+7)/8)
+	return unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), n)
+}
+
+func (sim *SimDriver) CreateBuffers(bufferDescriptors []BufferInfo, bufferSize int, callbacks Callbacks) error {
+	// Like real drivers, ask the host whether it handles bufferSwitchTimeInfo.
+	timeInfo := callbacks.AsioMessage != nil && callbacks.AsioMessage(kAsioSupportsTimeInfo, 0, 0, nil) == 1
+
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if sim.channels != nil {
+		return sim.fail("CreateBuffers", ErrorInvalidMode, "buffers already created")
+	}
+	if len(bufferDescriptors) == 0 {
+		return sim.fail("CreateBuffers", ErrorInvalidParameter, "no channels requested")
+	}
+	if !sim.validBufferSize(bufferSize) {
+		return sim.fail("CreateBuffers", ErrorInvalidMode, "buffer size %d not supported", bufferSize)
+	}
+
+	channels := make([]*simChannel, len(bufferDescriptors))
+	for i, desc := range bufferDescriptors {
+		if desc.Channel < 0 || desc.Channel >= sim.numChannels(desc.IsInput) {
+			return sim.fail("CreateBuffers", ErrorInvalidParameter, "channel %d does not exist", desc.Channel)
+		}
+		size := bufferSize * sim.channelType(desc.Channel, desc.IsInput).Size()
+		channels[i] = &simChannel{
+			isInput: desc.IsInput,
+			channel: desc.Channel,
+			buffers: [2][]byte{allocBuffer(size), allocBuffer(size)},
+		}
+	}
+
+	// Like a real driver, the sim reaches the host through the trampolines of a callback slot.
+	slot, err := acquireCallbackSlot(sim, callbacks)
+	if err != nil {
+		return sim.fail("CreateBuffers", ErrorNoMemory, "%s", err)
+	}
+
+	// Project buffer addresses back into input `[]BufferInfo`:
+	for i, c := range channels {
+		bufferDescriptors[i].Buffers = [2]*int32{
+			(*int32)(unsafe.Pointer(&c.buffers[0][0])),
+			(*int32)(unsafe.Pointer(&c.buffers[1][0])),
+		}
+	}
+
+	sim.channels = channels
+	sim.bufferSize = bufferSize
+	sim.slot = slot
+	sim.timeInfo = timeInfo
+	sim.index = 0
+	return nil
+}
+
+func (sim *SimDriver) DisposeBuffers() error {
+	// a Stop from a callback left the ticker running until the callback returns
+	sim.mu.Lock()
+	t, running := sim.ticker, sim.running
+	sim.mu.Unlock()
+	if !running {
+		t.wait()
+	}
+
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if sim.channels == nil {
+		return sim.fail("DisposeBuffers", ErrorInvalidMode, "no buffers created")
+	}
+	if sim.running {
+		return sim.fail("DisposeBuffers", ErrorInvalidMode, "driver is running")
+	}
+	sim.channels = nil
+	releaseCallbackSlot(sim)
+	return nil
+}
+
+func (sim *SimDriver) ControlPanel() error {
+	return nil
+}
+
+// Future supports querying time info and overload support, input monitoring,
+// time code reading when SimConfig.TimeCode is set, and switching to DSD
+// when SimConfig.DSD is set. Switching the format with buffers created
+// requests a reset from the host, like real drivers do.
+func (sim *SimDriver) Future(selector FutureSelector, opt unsafe.Pointer) error {
+	_, err := sim.futureCode(selector, opt)
+	return err
+}
+
+// futureCode is Future returning ASE_SUCCESS on success, like the ASIO SDK asks of drivers.
+func (sim *SimDriver) futureCode(selector FutureSelector, opt unsafe.Pointer) (int32, error) {
+	sim.mu.Lock()
+	reset, err := sim.future(selector, opt)
+	callbacks := sim.callbacks()
+	sim.mu.Unlock()
+
+	if reset && callbacks.AsioMessage != nil {
+		callbacks.AsioMessage(kAsioResetRequest, 0, 0, nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ASE_SUCCESS, nil
+}
+
+// future runs a Future call and tells whether the host must be asked for a reset. sim.mu must be held.
+func (sim *SimDriver) future(selector FutureSelector, opt unsafe.Pointer) (reset bool, err error) {
+	switch selector {
+	case FutureCanTimeInfo, FutureCanReportOverload, FutureCanInputMonitor:
+		return false, nil
+	case FutureTransport, FutureCanTransport,
+		FutureSetInputGain, FutureCanInputGain, FutureSetOutputGain, FutureCanOutputGain,
+		FutureGetInputMeter, FutureCanInputMeter, FutureGetOutputMeter, FutureCanOutputMeter:
+		return false, ErrorNotPresent
+	case FutureCanTimeCode, FutureEnableTimeCodeRead, FutureDisableTimeCodeRead:
+		if !sim.cfg.TimeCode {
+			return false, ErrorNotPresent
+		}
+		return false, nil
+	case FutureSetInputMonitor:
+		m := (*rawInputMonitor)(opt)
+		if m.input < -1 || int(m.input) >= sim.cfg.InputChannels || m.output < 0 || int(m.output) >= sim.cfg.OutputChannels {
+			return false, sim.fail("Future", ErrorInvalidParameter, "cannot monitor input %d on output %d", m.input, m.output)
+		}
+		return false, nil
+	case FutureGetInternalBufferSamples:
+		*(*rawInternalBufferInfo)(opt) = rawInternalBufferInfo{}
+		return false, nil
+	case FutureGetIoFormat:
+		(*rawIoFormat)(opt).formatType = int32(sim.ioFormat)
+		return false, nil
+	case FutureCanDoIoFormat, FutureSetIoFormat:
+		format := IoFormat((*rawIoFormat)(opt).formatType)
+		if format != IoFormatPCM && (format != IoFormatDSD || !sim.cfg.DSD) {
+			return false, ErrorNotPresent
+		}
+		if selector == FutureCanDoIoFormat || format == sim.ioFormat {
+			return false, nil
+		}
+		if sim.running {
+			return false, sim.fail("Future", ErrorInvalidMode, "cannot switch format while running")
+		}
+		sim.ioFormat = format
+		if format == IoFormatDSD {
+			sim.sampleRate = dsdSampleRate
+		} else {
+			sim.sampleRate = sim.cfg.SampleRates[0]
+		}
+		sim.rateChanged = true
+		return sim.channels != nil, nil
+	}
+	return false, ErrorInvalidParameter
+}
+
+func (sim *SimDriver) OutputReady() bool {
+	return true
+}
+
+// callbacks returns the host's callbacks, which are all nil without buffers. sim.mu must be held.
+func (sim *SimDriver) callbacks() Callbacks {
+	if sim.channels == nil {
+		return Callbacks{}
+	}
+	return slotCallbacks(sim.slot)
+}
+
+// halves returns the input and output buffers of one double buffer half, indexed by channel number.
+func (sim *SimDriver) halves(index int) (in, out [][]byte) {
+	in = make([][]byte, sim.cfg.InputChannels)
+	out = make([][]byte, sim.cfg.OutputChannels)
+	for _, c := range sim.channels {
+		if c.isInput {
+			in[c.channel] = c.buffers[index]
+		} else {
+			out[c.channel] = c.buffers[index]
+		}
+	}
+	return in, out
+}
+
+// Step advances the virtual clock by n buffer periods, invoking the host's
+// bufferSwitchTimeInfo callback once per period, or bufferSwitch for hosts
+// without time info support. It does nothing while the driver is stopped.
+func (sim *SimDriver) Step(n int) {
+	for range n {
+		if !sim.step() {
+			return
+		}
+	}
+}
+
+func (sim *SimDriver) step() bool {
+	sim.mu.Lock()
+	if !sim.running {
+		sim.mu.Unlock()
+		return false
+	}
+	index, callbacks := sim.index, sim.callbacks()
+	in, out := sim.halves(index)
+	var params *ASIOTime
+	if sim.timeInfo && callbacks.BufferSwitchTimeInfo != nil {
+		params = newASIOTime(sim.nextTimeInfo())
+	}
+	sim.mu.Unlock()
+
+	// Callbacks run without holding the lock, so they may call back into the driver.
+	if sim.cfg.OnInput != nil {
+		sim.cfg.OnInput(index, in)
+	}
+	if params != nil {
+		callbacks.BufferSwitchTimeInfo(params, int32(index), true)
+	} else if callbacks.BufferSwitch != nil {
+		callbacks.BufferSwitch(int32(index), true)
+	}
+	if sim.cfg.OnOutput != nil {
+		sim.cfg.OnOutput(index, out)
+	}
+
+	sim.mu.Lock()
+	sim.index ^= 1
+	sim.position += int64(sim.bufferSize)
+	sim.systemTime += int64(float64(sim.bufferSize) / sim.sampleRate * float64(time.Second))
+	sim.mu.Unlock()
+	return true
+}
+
+// nextTimeInfo describes the buffer about to be processed. sim.mu must be held.
+func (sim *SimDriver) nextTimeInfo() TimeInfo {
+	info := TimeInfo{
+		Speed:          1,
+		SystemTime:     sim.systemTime,
+		SamplePosition: sim.position,
+		SampleRate:     sim.sampleRate,
+		Flags:          TimeInfoSystemTimeValid | TimeInfoSamplePositionValid | TimeInfoSampleRateValid | TimeInfoSpeedValid,
+	}
+	if sim.rateChanged {
+		info.Flags |= TimeInfoSampleRateChanged
+		sim.rateChanged = false
+	}
+	if sim.clockChanged {
+		info.Flags |= TimeInfoClockSourceChanged
+		sim.clockChanged = false
+	}
+	if sim.cfg.TimeCode {
+		info.TimeCode = TimeCode{
+			Valid:   true,
+			Speed:   1,
+			Samples: sim.position,
+			Flags:   TimeCodeValid | TimeCodeRunning | TimeCodeOnSpeed | TimeCodeSpeedValid,
+		}
+	}
+	return info
+}
+
+// Position returns the number of samples processed since the driver was created.
+func (sim *SimDriver) Position() int64 {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return sim.position
+}
+
+// Running reports whether the driver has been started.
+func (sim *SimDriver) Running() bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return sim.running
+}
+
+// SendMessage delivers an asioMessage to the host, as a driver would, and returns the host's answer.
+func (sim *SimDriver) SendMessage(selector, value int32) int32 {
+	sim.mu.Lock()
+	callbacks := sim.callbacks()
+	sim.mu.Unlock()
+
+	if callbacks.AsioMessage == nil {
+		return 0
+	}
+	return callbacks.AsioMessage(selector, value, 0, nil)
+}
+
+// ChangeSampleRate emulates an external sample rate change, such as a new word clock,
+// and notifies the host through sampleRateDidChange.
+func (sim *SimDriver) ChangeSampleRate(rate float64) {
+	sim.mu.Lock()
+	sim.sampleRate = rate
+	sim.rateChanged = true
+	callbacks := sim.callbacks()
+	sim.mu.Unlock()
+
+	if callbacks.SampleRateDidChange != nil {
+		callbacks.SampleRateDidChange(rate)
+	}
+}
@@ -0,0 +1,265 @@
+package asio
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSimDriverBufferSwitch(t *testing.T) {
+	var indices []int
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  2,
+		OutputChannels: 2,
+		PreferredSize:  64,
+		OnInput: func(index int, in [][]byte) {
+			indices = append(indices, index)
+			for ch, buf := range in {
+				for i := 0; i < len(buf); i += 4 {
+					binary.LittleEndian.PutUint32(buf[i:], uint32(ch*1000+i/4))
+				}
+			}
+		},
+		OnOutput: func(index int, out [][]byte) {
+			for ch, buf := range out {
+				for i := 0; i < len(buf); i += 4 {
+					if got, want := int32(binary.LittleEndian.Uint32(buf[i:])), int32(-(ch*1000 + i/4)); got != want {
+						t.Fatalf("out[%d][%d] = %d, want %d", ch, i/4, got, want)
+					}
+				}
+			}
+		},
+	})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	calls := 0
+	if err := device.Start(func(in, out [][]int32) {
+		calls++
+		if len(in) != 2 || len(out) != 2 {
+			t.Fatalf("got %d inputs and %d outputs, want 2 and 2", len(in), len(out))
+		}
+		for ch := range out {
+			if len(out[ch]) != 64 {
+				t.Fatalf("buffer length %d, want 64", len(out[ch]))
+			}
+			for i := range out[ch] {
+				out[ch][i] = -in[ch][i]
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sim.Step(4)
+
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(4) // stopped: no callbacks
+
+	if calls != 4 {
+		t.Errorf("handler called %d times, want 4", calls)
+	}
+	if want := []int{0, 1, 0, 1}; !slices.Equal(indices, want) {
+		t.Errorf("buffer indices %v, want %v", indices, want)
+	}
+	if pos := sim.Position(); pos != 4*64 {
+		t.Errorf("position %d, want %d", pos, 4*64)
+	}
+}
+
+func TestSimDriverStopWaits(t *testing.T) {
+	device := Device{}
+	if err := device.LoadDriver(NewSimDriver(SimConfig{PreferredSize: 64, RealTime: true})); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	// no callback running nor starting once Stop returned
+	var active, calls atomic.Int32
+	for range 10 {
+		if err := device.Start(func(in, out [][]int32) {
+			active.Add(1)
+			time.Sleep(time.Millisecond)
+			calls.Add(1)
+			active.Add(-1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		for calls.Load() == 0 {
+			time.Sleep(100 * time.Microsecond)
+		}
+		if err := device.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		n := calls.Load()
+		if active.Load() != 0 {
+			t.Fatal("handler running after Stop")
+		}
+		time.Sleep(5 * time.Millisecond)
+		if calls.Load() != n {
+			t.Fatal("handler called after Stop")
+		}
+		calls.Store(0)
+	}
+
+	// Stop from a callback does not wait for itself, and Close waits for it
+	stopped := make(chan error)
+	if err := device.Start(func(in, out [][]int32) {
+		if calls.Add(1) == 1 {
+			stopped <- device.Stop()
+			time.Sleep(2 * time.Millisecond)
+			active.Store(1)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if active.Load() != 1 || calls.Load() != 1 {
+		t.Errorf("Close returned before the callback calling Stop")
+	}
+}
+
+func TestSimDriverValidation(t *testing.T) {
+	sim := NewSimDriver(SimConfig{MinSize: 32, MaxSize: 512, PreferredSize: 128, Granularity: 32})
+
+	if err := sim.Start(); !errors.Is(err, ErrorInvalidMode) {
+		t.Errorf("Start without buffers: %v, want %v", err, ErrorInvalidMode)
+	}
+
+	buffers := []BufferInfo{{Channel: 0, IsInput: true}}
+	if err := sim.CreateBuffers(buffers, 100, Callbacks{}); !errors.Is(err, ErrorInvalidMode) {
+		t.Errorf("CreateBuffers(100): %v, want %v", err, ErrorInvalidMode)
+	}
+	if err := sim.CreateBuffers([]BufferInfo{{Channel: 5, IsInput: true}}, 96, Callbacks{}); !errors.Is(err, ErrorInvalidParameter) {
+		t.Errorf("CreateBuffers(channel 5): %v, want %v", err, ErrorInvalidParameter)
+	}
+	if err := sim.CreateBuffers(buffers, 96, Callbacks{}); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.DisposeBuffers() // frees the callback slot
+	if buffers[0].Buffers[0] == nil || buffers[0].Buffers[1] == nil {
+		t.Error("buffer addresses not returned")
+	}
+	if err := sim.CreateBuffers(buffers, 96, Callbacks{}); !errors.Is(err, ErrorInvalidMode) {
+		t.Errorf("second CreateBuffers: %v, want %v", err, ErrorInvalidMode)
+	}
+
+	if err := sim.CanSampleRate(12345); !errors.Is(err, ErrorNoClock) {
+		t.Errorf("CanSampleRate(12345): %v, want %v", err, ErrorNoClock)
+	}
+	if err := sim.SetSampleRate(48000); err != nil {
+		t.Fatal(err)
+	}
+	if rate, _ := sim.GetSampleRate(); rate != 48000 {
+		t.Errorf("sample rate %v, want 48000", rate)
+	}
+}
+
+func TestSimDriverChannelInfo(t *testing.T) {
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 2,
+		OutputTypes:    []SampleType{ASIOSTFloat32LSB},
+		OutputNames:    []string{"Main L"},
+	})
+
+	info, err := sim.GetChannelInfo(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SampleType != ASIOSTFloat32LSB || info.Name != "Main L" {
+		t.Errorf("out 0: %+v", info)
+	}
+	if info, _ = sim.GetChannelInfo(1, false); info.SampleType != ASIOSTInt32LSB || info.Name != "Out 2" {
+		t.Errorf("out 1: %+v", info)
+	}
+	if _, err = sim.GetChannelInfo(1, true); !errors.Is(err, ErrorInvalidParameter) {
+		t.Errorf("in 1: %v, want %v", err, ErrorInvalidParameter)
+	}
+}
+
+func TestSessionSim(t *testing.T) {
+	sim := NewSimDriver(SimConfig{})
+	calls := 0
+	err := Session{
+		Driver:     sim,
+		SampleRate: 48000,
+		IOHandler: func(in, out [][]int32) {
+			calls++
+		},
+		WaitFunc: func() {
+			sim.Step(10)
+		},
+	}.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 10 {
+		t.Errorf("handler called %d times, want 10", calls)
+	}
+	if rate, _ := sim.GetSampleRate(); rate != 48000 {
+		t.Errorf("sample rate %v, want 48000", rate)
+	}
+}
+
+func TestRegisterDriver(t *testing.T) {
+	sim := NewSimDriver(SimConfig{Name: "Test Interface"})
+	RegisterDriver("Test Interface", func() (Driver, error) { return sim, nil })
+	defer RegisterDriver("Test Interface", nil)
+
+	drivers, err := ListDrivers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drivers["Test Interface"] == nil {
+		t.Fatalf("registered driver missing from %v", drivers)
+	}
+	if drivers["ASIO Simulator"] != nil {
+		t.Error("simulator listed without being registered")
+	}
+
+	device := Device{}
+	if err = device.Load("Test Interface"); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+
+	if err = device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err = device.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !sim.Running() {
+		t.Error("loaded driver is not the registered instance")
+	}
+	if err = device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = device.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package asio
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// State is the lifecycle state of a Device, after the host state diagram of the ASIO SDK:
+//
+//	Unloaded -Load-> Loaded -init-> Initialized -Open-> Prepared -Start-> Running
+//
+// Stop, Close and Unload go back the same way. While they wait for the
+// driver's buffer callback to return, the device is Stopping.
+type State int
+
+const (
+	StateUnloaded    State = iota // no driver
+	StateLoaded                   // driver selected but not initialized; only Unload is allowed
+	StateInitialized              // driver initialized, no buffers
+	StatePrepared                 // buffers created, driver stopped
+	StateRunning                  // driver started
+	StateStopping                 // waiting for the driver to stop, or to dispose its buffers
+)
+
+var stateNames = [...]string{
+	StateUnloaded:    "Unloaded",
+	StateLoaded:      "Loaded",
+	StateInitialized: "Initialized",
+	StatePrepared:    "Prepared",
+	StateRunning:     "Running",
+	StateStopping:    "Stopping",
+}
+
+func (s State) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// ErrInvalidState matches every StateError with errors.Is.
+var ErrInvalidState = errors.New("invalid device state")
+
+// StateError reports a Device method called in a state that does not allow it.
+type StateError struct {
+	Op    string // the method called
+	State State  // the state of the device at the call
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("%s not allowed in state %s", e.Op, e.State)
+}
+
+func (e *StateError) Is(target error) bool {
+	return target == ErrInvalidState
+}
+
+// State returns the lifecycle state of the device.
+func (dev *Device) State() State {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	return dev.state
+}
+
+// require returns a StateError unless the device is in one of states. dev.mu must be held.
+func (dev *Device) require(op string, states ...State) error {
+	if slices.Contains(states, dev.state) {
+		return nil
+	}
+	return &StateError{Op: op, State: dev.state}
+}
@@ -0,0 +1,113 @@
+package asio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeviceStateTransitions(t *testing.T) {
+	sim := NewSimDriver(SimConfig{})
+	device := Device{}
+
+	wantState := func(want State) {
+		t.Helper()
+		if got := device.State(); got != want {
+			t.Fatalf("state %v, want %v", got, want)
+		}
+	}
+	wantStateError := func(op string, err error) {
+		t.Helper()
+		var stateErr *StateError
+		if !errors.Is(err, ErrInvalidState) || !errors.As(err, &stateErr) || stateErr.Op != op {
+			t.Errorf("%s: got error %v, want a StateError", op, err)
+		}
+	}
+
+	wantState(StateUnloaded)
+	wantStateError("Open", device.Open())
+	wantStateError("Start", device.Start(nil))
+	wantStateError("Stop", device.Stop())
+	wantStateError("SetSampleRate", device.SetSampleRate(48000))
+
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	wantState(StateInitialized)
+	wantStateError("LoadDriver", device.LoadDriver(sim))
+	wantStateError("Start", device.Start(nil))
+	wantStateError("Reset", device.Reset())
+
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	wantState(StatePrepared)
+	wantStateError("Open", device.Open())
+	wantStateError("Stop", device.Stop())
+
+	if err := device.Start(func(in, out [][]int32) {}); err != nil {
+		t.Fatal(err)
+	}
+	wantState(StateRunning)
+	wantStateError("Start", device.Start(nil))
+
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	wantState(StatePrepared)
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wantState(StateInitialized)
+	if err := device.Close(); err != nil {
+		t.Errorf("Close without buffers: %v", err)
+	}
+
+	device.Unload()
+	wantState(StateUnloaded)
+}
+
+func TestDeviceTeardownFromRunning(t *testing.T) {
+	sim := NewSimDriver(SimConfig{})
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Start(func(in, out [][]int32) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := device.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Running() || device.State() != StateInitialized {
+		t.Errorf("Close while running left the driver running: state %v", device.State())
+	}
+
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Start(func(in, out [][]int32) {}); err != nil {
+		t.Fatal(err)
+	}
+	device.Unload()
+	if sim.Running() || device.State() != StateUnloaded {
+		t.Errorf("Unload while running left the driver running: state %v", device.State())
+	}
+	if err := sim.DisposeBuffers(); err == nil {
+		t.Error("Unload left buffers behind")
+	}
+	device.Unload() // again, from Unloaded
+}
+
+func TestDeviceLoadUnknown(t *testing.T) {
+	device := Device{}
+	if err := device.Load("no such driver"); err == nil {
+		t.Fatal("Load of an unknown driver succeeded")
+	}
+	if state := device.State(); state != StateUnloaded {
+		t.Errorf("state %v after failed Load, want Unloaded", state)
+	}
+}
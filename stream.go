@@ -0,0 +1,263 @@
+package asio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+var (
+	// ErrInputOverflow is returned by Stream.Read when input frames were dropped
+	// because they were not read in time.
+	ErrInputOverflow = errors.New("input overflow")
+	// ErrOutputUnderflow is returned by Stream.Write when the driver played
+	// silence because output frames were not written in time.
+	ErrOutputUnderflow = errors.New("output underflow")
+	// ErrStreamClosed is returned by Stream.Write after Close, and by
+	// Stream.Read once the frames recorded before Close are drained.
+	ErrStreamClosed = errors.New("stream closed")
+)
+
+// defaultStreamBuffers is used when StreamOptions.Buffers is zero.
+const defaultStreamBuffers = 2
+
+// StreamOptions configures a Stream.
+type StreamOptions struct {
+	// Buffers is the number of driver buffers queued between the driver and
+	// the stream in each direction; zero selects 2. More buffers tolerate
+	// slower readers and writers, at the cost of latency.
+	Buffers int
+}
+
+// Stream reads and writes the channels of a Device with blocking calls from
+// ordinary goroutines, instead of a handler running on the driver's thread.
+// The driver's buffers are queued in ring buffers in between.
+//
+// Read and Write may be called from different goroutines, but neither from
+// several goroutines at once.
+//
+// When every channel is ASIOSTInt32LSB, the stream queues the driver's samples
+// as int32, so that ReadInt32 and WriteInt32, and the integer formats of
+// PCMReader and PCMWriter, keep all their bits.
+type Stream struct {
+	dev       *Device
+	ints      bool        // in and out are *RingBuffer[int32], else *RingBuffer[float32]
+	in, out   sampleRing  // nil without input or output channels
+	overruns  uint64      // input overruns already reported by Read
+	underruns uint64      // output underruns already reported by Write
+	unplayed  atomic.Bool // started without output queued; reset by Write
+
+	// buffers converting the samples of Read and Write when in and out hold the other type
+	readInts    [][]int32
+	readFloats  [][]float32
+	writeInts   [][]int32
+	writeFloats [][]float32
+}
+
+// OpenStream returns a stream on the channels of an opened device. Samples
+// are normalized like with StartFloat.
+func OpenStream(dev *Device, opts StreamOptions) (*Stream, error) {
+	if opts.Buffers < 0 {
+		return nil, fmt.Errorf("stream: invalid number of buffers %d", opts.Buffers)
+	}
+	buffers := opts.Buffers
+	if buffers == 0 {
+		buffers = defaultStreamBuffers
+	}
+
+	dev.lock()
+	defer dev.mu.Unlock()
+	if err := dev.require("OpenStream", StatePrepared); err != nil {
+		return nil, err
+	}
+	if dev.codecErr != nil {
+		return nil, dev.codecErr
+	}
+
+	s := &Stream{dev: dev, ints: true}
+	for _, c := range dev.channels {
+		s.ints = s.ints && c.SampleType == ASIOSTInt32LSB
+	}
+	frames := buffers * dev.bufferSize
+	if n := dev.numInputs; n > 0 {
+		s.in = s.newRing(n, frames)
+	}
+	if n := len(dev.channels) - dev.numInputs; n > 0 {
+		s.out = s.newRing(n, frames)
+	}
+	return s, nil
+}
+
+func (s *Stream) newRing(channels, frames int) sampleRing {
+	if s.ints {
+		return NewRingBuffer[int32](channels, frames)
+	}
+	return NewRingBuffer[float32](channels, frames)
+}
+
+// Start starts the device. Output frames written before are played first;
+// without them, the driver plays silence until the first Write, which is not
+// reported as an underflow.
+func (s *Stream) Start() error {
+	s.unplayed.Store(s.out != nil && s.out.Len() == 0)
+	if s.ints {
+		in, _ := s.in.(*RingBuffer[int32])
+		out, _ := s.out.(*RingBuffer[int32])
+		return s.dev.Start(RingHandler(in, out))
+	}
+	in, _ := s.in.(*RingBuffer[float32])
+	out, _ := s.out.(*RingBuffer[float32])
+	return s.dev.StartFloat(RingHandler(in, out))
+}
+
+// Stop stops the device. Frames already queued stay in the stream.
+func (s *Stream) Stop() error {
+	return s.dev.Stop()
+}
+
+// Close stops the device if it is running, and closes the stream; the device
+// is left open. Blocked and later calls to Write return ErrStreamClosed. Read
+// still returns the frames recorded before Close: once fewer than buf holds
+// are left, it copies them to the start of buf and returns ErrStreamClosed.
+func (s *Stream) Close() error {
+	if s.in != nil {
+		s.in.Close()
+	}
+	if s.out != nil {
+		s.out.Close()
+	}
+	if s.dev.State() == StateRunning {
+		return s.dev.Stop()
+	}
+	return nil
+}
+
+// Read fills the buffers of buf, one per input channel, waiting for the driver to record the frames.
+//
+// When frames were dropped since the previous Read because the stream was not
+// read in time, buf is still filled but ErrInputOverflow is returned.
+func (s *Stream) Read(buf [][]float32) error {
+	return streamRead(s, buf)
+}
+
+// ReadInt32 is like Read, with samples scaled to the full range of int32.
+func (s *Stream) ReadInt32(buf [][]int32) error {
+	return streamRead(s, buf)
+}
+
+func streamRead[T int32 | float32](s *Stream, buf [][]T) error {
+	if s.in == nil {
+		return errors.New("stream: no input channels")
+	}
+	var err error
+	switch in := s.in.(type) {
+	case *RingBuffer[T]:
+		_, err = in.ReadWait(context.Background(), buf)
+	case *RingBuffer[int32]:
+		s.readInts = resizeBufs(s.readInts, len(buf), numFrames(buf))
+		_, err = in.ReadWait(context.Background(), s.readInts)
+		convertSamples(buf, s.readInts)
+	case *RingBuffer[float32]:
+		s.readFloats = resizeBufs(s.readFloats, len(buf), numFrames(buf))
+		_, err = in.ReadWait(context.Background(), s.readFloats)
+		convertSamples(buf, s.readFloats)
+	}
+	if err != nil {
+		if err == io.EOF {
+			return ErrStreamClosed
+		}
+		return err
+	}
+	if overruns := s.in.Overruns(); overruns != s.overruns {
+		dropped := overruns - s.overruns
+		s.overruns = overruns
+		return fmt.Errorf("stream: %w, %d frames dropped", ErrInputOverflow, dropped)
+	}
+	return nil
+}
+
+// Write queues the buffers of buf, one per output channel, waiting for room
+// when more than the configured number of buffers are queued.
+//
+// When the driver ran out of frames to play since the previous Write, buf is
+// still queued but ErrOutputUnderflow is returned.
+func (s *Stream) Write(buf [][]float32) error {
+	return streamWrite(s, buf)
+}
+
+// WriteInt32 is like Write, with samples scaled to the full range of int32.
+func (s *Stream) WriteInt32(buf [][]int32) error {
+	return streamWrite(s, buf)
+}
+
+func streamWrite[T int32 | float32](s *Stream, buf [][]T) error {
+	if s.out == nil {
+		return errors.New("stream: no output channels")
+	}
+	if s.unplayed.Swap(false) {
+		s.underruns = s.out.Underruns()
+	}
+	var err error
+	switch out := s.out.(type) {
+	case *RingBuffer[T]:
+		_, err = out.WriteWait(context.Background(), buf)
+	case *RingBuffer[int32]:
+		s.writeInts = resizeBufs(s.writeInts, len(buf), numFrames(buf))
+		convertSamples(s.writeInts, buf)
+		_, err = out.WriteWait(context.Background(), s.writeInts)
+	case *RingBuffer[float32]:
+		s.writeFloats = resizeBufs(s.writeFloats, len(buf), numFrames(buf))
+		convertSamples(s.writeFloats, buf)
+		_, err = out.WriteWait(context.Background(), s.writeFloats)
+	}
+	if err != nil {
+		if err == io.ErrClosedPipe {
+			return ErrStreamClosed
+		}
+		return err
+	}
+	if underruns := s.out.Underruns(); underruns != s.underruns {
+		missing := underruns - s.underruns
+		s.underruns = underruns
+		return fmt.Errorf("stream: %w, %d frames missing", ErrOutputUnderflow, missing)
+	}
+	return nil
+}
+
+// ReadAvailable returns the number of frames Read can return without waiting.
+func (s *Stream) ReadAvailable() int {
+	if s.in == nil {
+		return 0
+	}
+	return s.in.Len()
+}
+
+// WriteAvailable returns the number of frames Write can queue without waiting.
+func (s *Stream) WriteAvailable() int {
+	if s.out == nil {
+		return 0
+	}
+	return s.out.Free()
+}
+
+// convertSamples converts the samples of src, scaled to the full range of
+// int32 or normalized, to the other type in dst, like the codec of
+// ASIOSTInt32LSB does.
+func convertSamples[T, U int32 | float32](dst [][]T, src [][]U) {
+	switch dst := any(dst).(type) {
+	case [][]float32:
+		for i, buf := range any(src).([][]int32) {
+			for j, v := range buf {
+				dst[i][j] = float32(float64(v) / (1 << 31))
+			}
+		}
+	case [][]int32:
+		for i, buf := range any(src).([][]float32) {
+			for j, x := range buf {
+				dst[i][j] = quantize(float64(x), 32)
+			}
+		}
+	}
+}
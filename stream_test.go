@@ -0,0 +1,259 @@
+package asio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newStreamSim returns a float32 sim recording a ramp on its input, and what it played.
+func newStreamSim(bufferSize int, realTime bool) (sim *SimDriver, sent, played func() []float32) {
+	var mu sync.Mutex
+	var in, out []float32
+	sim = NewSimDriver(SimConfig{
+		InputChannels:  1,
+		OutputChannels: 1,
+		PreferredSize:  bufferSize,
+		SampleType:     ASIOSTFloat32LSB,
+		RealTime:       realTime,
+		OnInput: func(_ int, bufs [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := 0; i < len(bufs[0]); i += 4 {
+				v := float32(len(in)%1000) / 1000
+				binary.LittleEndian.PutUint32(bufs[0][i:], math.Float32bits(v))
+				in = append(in, v)
+			}
+		},
+		OnOutput: func(_ int, bufs [][]byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := 0; i < len(bufs[0]); i += 4 {
+				out = append(out, math.Float32frombits(binary.LittleEndian.Uint32(bufs[0][i:])))
+			}
+		},
+	})
+	get := func(s *[]float32) func() []float32 {
+		return func() []float32 {
+			mu.Lock()
+			defer mu.Unlock()
+			return slices.Clone(*s)
+		}
+	}
+	return sim, get(&in), get(&out)
+}
+
+func openStream(t *testing.T, sim *SimDriver, opts StreamOptions) *Stream {
+	t.Helper()
+	device := &Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(device.Unload)
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenStream(device, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStream(t *testing.T) {
+	const bufferSize = 64
+	sim, sent, played := newStreamSim(bufferSize, false)
+	s := openStream(t, sim, StreamOptions{Buffers: 3})
+
+	if s.ReadAvailable() != 0 || s.WriteAvailable() != 3*bufferSize {
+		t.Fatalf("available %d to read and %d to write", s.ReadAvailable(), s.WriteAvailable())
+	}
+	// prime the output with one buffer, and loop the input back
+	buf := [][]float32{make([]float32, bufferSize)}
+	if err := s.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for range 8 {
+		sim.Step(1)
+		if s.ReadAvailable() != bufferSize {
+			t.Fatalf("%d frames available to read after one buffer", s.ReadAvailable())
+		}
+		if err := s.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := append(make([]float32, bufferSize), sent()[:7*bufferSize]...)
+	if got := played(); !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+
+	// not reading for more than the buffers of the stream drops input
+	sim.Step(4)
+	if err := s.Read(buf); !errors.Is(err, ErrInputOverflow) {
+		t.Errorf("Read after overflow: %v", err)
+	}
+	if in := sent(); buf[0][0] != in[len(in)-4*bufferSize] {
+		t.Errorf("read %v after overflow, want the oldest frames kept", buf[0][0])
+	}
+	if err := s.Read(buf); err != nil {
+		t.Errorf("Read after the overflow was reported: %v", err)
+	}
+
+	// the previous write is played, then silence
+	if err := s.Write(buf); !errors.Is(err, ErrOutputUnderflow) {
+		t.Errorf("Write after underflow: %v", err)
+	}
+	if err := s.Write(buf); err != nil {
+		t.Errorf("Write after the underflow was reported: %v", err)
+	}
+}
+
+func TestStreamStartSilent(t *testing.T) {
+	sim, _, _ := newStreamSim(64, false)
+	s := openStream(t, sim, StreamOptions{})
+	buf := [][]float32{make([]float32, 64)}
+	for range 2 {
+		// the silence played before the first Write is not an underflow
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		sim.Step(2)
+		if err := s.Write(buf); err != nil {
+			t.Errorf("first Write after Start: %v", err)
+		}
+		sim.Step(2)
+		if err := s.Write(buf); !errors.Is(err, ErrOutputUnderflow) {
+			t.Errorf("Write after underflow: %v", err)
+		}
+		sim.Step(1)
+		if err := s.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStreamInt32(t *testing.T) {
+	// a float32 device converts the samples of ReadInt32 and WriteInt32
+	sim, sent, played := newStreamSim(64, false)
+	s := openStream(t, sim, StreamOptions{})
+	buf := [][]int32{make([]int32, 64)}
+	for i := range buf[0] {
+		buf[0][i] = int32(i) << 24
+	}
+	if err := s.WriteInt32(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	if err := s.ReadInt32(buf); err != nil {
+		t.Fatal(err)
+	}
+	in, out := sent(), played()
+	for i := range 64 {
+		if want := float32(i) / 128; out[i] != want {
+			t.Fatalf("frame %d played as %v, want %v", i, out[i], want)
+		}
+		if want := quantize(float64(in[i]), 32); buf[0][i] != want {
+			t.Fatalf("frame %d read as %d, want %d", i, buf[0][i], want)
+		}
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	sim, _, _ := newStreamSim(64, false)
+	s := openStream(t, sim, StreamOptions{Buffers: 1})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- s.Read([][]float32{make([]float32, 64)}) }()
+	go func() { errs <- s.Write([][]float32{make([]float32, 128)}) }()
+	time.Sleep(10 * time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := <-errs; err != ErrStreamClosed {
+			t.Errorf("blocked call returned %v after Close", err)
+		}
+	}
+	if state := s.dev.State(); state != StatePrepared {
+		t.Errorf("device %v after Close", state)
+	}
+
+	// frames recorded before Close are drained
+	sim, sent, _ := newStreamSim(64, false)
+	s = openStream(t, sim, StreamOptions{})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf := [][]float32{make([]float32, 48)}
+	if err := s.Read(buf); err != nil {
+		t.Errorf("Read of queued frames after Close: %v", err)
+	}
+	if err := s.Read(buf); err != ErrStreamClosed {
+		t.Errorf("Read of the last frames after Close: %v", err)
+	}
+	if in := sent(); !slices.Equal(buf[0][:16], in[48:64]) {
+		t.Errorf("last frames %v, want %v", buf[0][:16], in[48:64])
+	}
+	if err := s.Read(buf); err != ErrStreamClosed {
+		t.Errorf("Read after draining: %v", err)
+	}
+
+	if _, err := OpenStream(&Device{}, StreamOptions{}); err == nil {
+		t.Error("stream on a device without driver")
+	}
+}
+
+func TestStreamRealTime(t *testing.T) {
+	const bufferSize, frames = 512, 8192
+	sim, sent, played := newStreamSim(bufferSize, true)
+	s := openStream(t, sim, StreamOptions{Buffers: 8})
+
+	// an output queue of two buffers absorbs the scheduling of the goroutine
+	buf := [][]float32{make([]float32, bufferSize)}
+	for range 2 {
+		if err := s.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < frames; n += bufferSize {
+		if err := s.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := played()[2*bufferSize:]
+	want := sent()[:len(got)]
+	if len(got) < frames/2 || !slices.Equal(got, want) {
+		t.Errorf("played %d frames differing from the input", len(got))
+	}
+}
@@ -0,0 +1,103 @@
+package asio
+
+// TimeInfoFlags tell which fields of a TimeInfo are valid.
+type TimeInfoFlags uint32
+
+const (
+	TimeInfoSystemTimeValid     TimeInfoFlags = 1 << iota // must always be valid
+	TimeInfoSamplePositionValid                           // must always be valid
+	TimeInfoSampleRateValid
+	TimeInfoSpeedValid
+	TimeInfoSampleRateChanged
+	TimeInfoClockSourceChanged
+)
+
+// TimeCodeFlags describe the state of a TimeCode.
+type TimeCodeFlags uint32
+
+const (
+	TimeCodeValid TimeCodeFlags = 1 << iota
+	TimeCodeRunning
+	TimeCodeReverse
+	TimeCodeOnSpeed
+	TimeCodeStill
+
+	TimeCodeSpeedValid TimeCodeFlags = 1 << 8
+)
+
+// TimeInfo is the timing of a buffer, as reported by the driver with bufferSwitchTimeInfo.
+type TimeInfo struct {
+	Speed          float64 // absolute speed, 1 is nominal
+	SystemTime     int64   // system time of SamplePosition, in nanoseconds
+	SamplePosition int64   // position of the first sample of the buffer
+	SampleRate     float64
+	Flags          TimeInfoFlags
+
+	// TimeCode is only meaningful when TimeCode.Valid is set.
+	TimeCode TimeCode
+}
+
+// TimeCode is an external time code, such as MTC or LTC, received by the driver.
+type TimeCode struct {
+	Valid   bool    // the driver reports a valid time code; the other fields are zero otherwise
+	Speed   float64 // relative to nominal speed
+	Samples int64   // time code position in samples
+	Flags   TimeCodeFlags
+}
+
+func (s asioSamples) int64() int64 {
+	return int64(uint64(s.hi)<<32 | uint64(s.lo))
+}
+
+func newASIOSamples(n int64) asioSamples {
+	return asioSamples{hi: uint32(uint64(n) >> 32), lo: uint32(n)}
+}
+
+func (t asioTimeStamp) int64() int64 {
+	return int64(uint64(t.hi)<<32 | uint64(t.lo))
+}
+
+func newASIOTimeStamp(ns int64) asioTimeStamp {
+	return asioTimeStamp{hi: uint32(uint64(ns) >> 32), lo: uint32(ns)}
+}
+
+// TimeInfo decodes the driver's time information.
+func (t *ASIOTime) TimeInfo() TimeInfo {
+	info := TimeInfo{
+		Speed:          t.timeInfo.speed,
+		SystemTime:     t.timeInfo.systemTime.int64(),
+		SamplePosition: t.timeInfo.samplePosition.int64(),
+		SampleRate:     t.timeInfo.sampleRate,
+		Flags:          TimeInfoFlags(t.timeInfo.flags),
+	}
+	if flags := TimeCodeFlags(t.timeCode.flags); flags&TimeCodeValid != 0 {
+		info.TimeCode = TimeCode{
+			Valid:   true,
+			Speed:   t.timeCode.speed,
+			Samples: t.timeCode.timeCodeSamples.int64(),
+			Flags:   flags,
+		}
+	}
+	return info
+}
+
+// newASIOTime encodes info as a driver passes it to bufferSwitchTimeInfo.
+func newASIOTime(info TimeInfo) *ASIOTime {
+	t := &ASIOTime{
+		timeInfo: asioTimeInfo{
+			speed:          info.Speed,
+			systemTime:     newASIOTimeStamp(info.SystemTime),
+			samplePosition: newASIOSamples(info.SamplePosition),
+			sampleRate:     info.SampleRate,
+			flags:          uint32(info.Flags),
+		},
+	}
+	if tc := info.TimeCode; tc.Valid {
+		t.timeCode = asioTimeCode{
+			speed:           tc.Speed,
+			timeCodeSamples: newASIOSamples(tc.Samples),
+			flags:           uint32(tc.Flags | TimeCodeValid),
+		}
+	}
+	return t
+}
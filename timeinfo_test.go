@@ -0,0 +1,164 @@
+package asio
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+func TestASIOTimeLayout(t *testing.T) {
+	var params ASIOTime
+	if size := unsafe.Sizeof(params); size != 152 {
+		t.Errorf("ASIOTime is %d bytes, want 152", size)
+	}
+	for _, tc := range []struct {
+		name   string
+		offset uintptr
+		want   uintptr
+	}{
+		{"timeInfo", unsafe.Offsetof(params.timeInfo), 16},
+		{"timeInfo.samplePosition", unsafe.Offsetof(params.timeInfo.samplePosition), 16},
+		{"timeInfo.flags", unsafe.Offsetof(params.timeInfo.flags), 32},
+		{"timeCode", unsafe.Offsetof(params.timeCode), 64},
+		{"timeCode.flags", unsafe.Offsetof(params.timeCode.flags), 16},
+	} {
+		if tc.offset != tc.want {
+			t.Errorf("offset of %s is %d, want %d", tc.name, tc.offset, tc.want)
+		}
+	}
+}
+
+func TestTimeInfoRoundTrip(t *testing.T) {
+	for _, info := range []TimeInfo{
+		{},
+		{
+			Speed:          1,
+			SystemTime:     1<<40 + 12345,
+			SamplePosition: 1<<33 + 7,
+			SampleRate:     96000,
+			Flags:          TimeInfoSystemTimeValid | TimeInfoSamplePositionValid | TimeInfoSampleRateChanged,
+		},
+		{
+			Speed:          0.5,
+			SamplePosition: 4096,
+			TimeCode:       TimeCode{Valid: true, Speed: 1, Samples: 1 << 35, Flags: TimeCodeValid | TimeCodeReverse},
+		},
+	} {
+		if got := newASIOTime(info).TimeInfo(); !reflect.DeepEqual(got, info) {
+			t.Errorf("round trip of %+v gave %+v", info, got)
+		}
+	}
+
+	params := newASIOTime(TimeInfo{})
+	params.timeCode.speed = 1 // not flagged valid
+	if info := params.TimeInfo(); info.TimeCode != (TimeCode{}) {
+		t.Errorf("invalid time code decoded: %+v", info.TimeCode)
+	}
+	params.timeCode.flags = uint32(TimeCodeValid)
+	if allocs := testing.AllocsPerRun(10, func() { params.TimeInfo() }); allocs != 0 {
+		t.Errorf("TimeInfo allocates %v times", allocs)
+	}
+	params.timeCode = asioTimeCode{}
+	params.timeInfo.samplePosition = asioSamples{hi: 1, lo: 0xffffffff}
+	if pos := params.TimeInfo().SamplePosition; pos != 1<<33-1 {
+		t.Errorf("sample position %d from hi/lo, want %d", pos, int64(1<<33-1))
+	}
+}
+
+func TestDeviceStartWithTime(t *testing.T) {
+	sim := NewSimDriver(SimConfig{PreferredSize: 480, Granularity: 32, SampleRates: []float64{48000, 96000}, TimeCode: true})
+
+	device := Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	var infos []TimeInfo
+	if err := device.StartFloatWithTime(func(in, out [][]float32, info TimeInfo) {
+		infos = append(infos, info)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(2)
+	sim.ChangeSampleRate(96000)
+	sim.Step(1)
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 3 {
+		t.Fatalf("handler called %d times, want 3", len(infos))
+	}
+	valid := TimeInfoSystemTimeValid | TimeInfoSamplePositionValid | TimeInfoSampleRateValid | TimeInfoSpeedValid
+	for i, want := range []struct {
+		position, systemTime int64
+		rate                 float64
+		flags                TimeInfoFlags
+	}{
+		{0, 0, 48000, valid},
+		{480, 10e6, 48000, valid},
+		{960, 20e6, 96000, valid | TimeInfoSampleRateChanged},
+	} {
+		info := infos[i]
+		if info.SamplePosition != want.position || info.SystemTime != want.systemTime ||
+			info.SampleRate != want.rate || info.Flags != want.flags || info.Speed != 1 {
+			t.Errorf("buffer %d: %+v, want %+v", i, info, want)
+		}
+		if !info.TimeCode.Valid || info.TimeCode.Samples != want.position {
+			t.Errorf("buffer %d: time code %+v, want %d samples", i, info.TimeCode, want.position)
+		}
+	}
+}
+
+// noTimeInfoDriver is a driver without bufferSwitchTimeInfo support.
+type noTimeInfoDriver struct {
+	*SimDriver
+}
+
+func (drv noTimeInfoDriver) CreateBuffers(bufferDescriptors []BufferInfo, bufferSize int, callbacks Callbacks) error {
+	callbacks.BufferSwitchTimeInfo = nil
+	return drv.SimDriver.CreateBuffers(bufferDescriptors, bufferSize, callbacks)
+}
+
+func TestDeviceStartWithTimeFromSamplePosition(t *testing.T) {
+	sim := NewSimDriver(SimConfig{PreferredSize: 64})
+
+	device := Device{}
+	if err := device.LoadDriver(noTimeInfoDriver{sim}); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	if _, _, err := device.GetSamplePosition(); !errors.Is(err, ErrorSPNotAdvancing) {
+		t.Errorf("GetSamplePosition while stopped: %v, want %v", err, ErrorSPNotAdvancing)
+	}
+
+	var positions []int64
+	if err := device.StartWithTime(func(in, out [][]int32, info TimeInfo) {
+		if info.Flags != TimeInfoSystemTimeValid|TimeInfoSamplePositionValid {
+			t.Errorf("flags %b", info.Flags)
+		}
+		positions = append(positions, info.SamplePosition)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(3)
+
+	if want := []int64{0, 64, 128}; !slices.Equal(positions, want) {
+		t.Errorf("positions %v, want %v", positions, want)
+	}
+	if pos, _, err := device.GetSamplePosition(); err != nil || pos != 192 {
+		t.Errorf("GetSamplePosition() = %d, %v, want 192", pos, err)
+	}
+}
@@ -0,0 +1,385 @@
+package asio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"sync"
+)
+
+// Speaker positions of WAVEFORMATEXTENSIBLE channel masks.
+const (
+	SpeakerFrontLeft uint32 = 1 << iota
+	SpeakerFrontRight
+	SpeakerFrontCenter
+	SpeakerLowFrequency
+	SpeakerBackLeft
+	SpeakerBackRight
+	SpeakerFrontLeftOfCenter
+	SpeakerFrontRightOfCenter
+	SpeakerBackCenter
+	SpeakerSideLeft
+	SpeakerSideRight
+)
+
+const (
+	waveFormatPCM        = 0x0001
+	waveFormatIEEEFloat  = 0x0003
+	waveFormatExtensible = 0xFFFE
+)
+
+// KSDATAFORMAT_SUBTYPE_PCM and _IEEE_FLOAT differ in their first two bytes, the format tag.
+var waveSubFormatGUID = [14]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// RecorderOptions configures a Recorder.
+type RecorderOptions struct {
+	// Input channels to record, by index in the handler's input buffers, in
+	// the order of the file. nil records every channel.
+	Channels []int
+	// Format of the samples in the file; zero selects PCMS24LE.
+	Format PCMFormat
+	// ChannelMask assigns speaker positions to the first channels, such as
+	// SpeakerFrontLeft|SpeakerFrontRight; zero leaves every channel unassigned,
+	// as usual for multitrack recordings.
+	ChannelMask uint32
+	// Frames is the capacity of the ring buffer between the handler and the
+	// file; zero selects one second. It must absorb the stalls of the disk.
+	Frames int
+}
+
+// Recorder writes input channels to a WAVE_FORMAT_EXTENSIBLE file. Its handler
+// only queues the samples in a ring buffer, and a goroutine writes them, so
+// that the disk cannot cause dropouts; frames not fitting in the ring buffer
+// are dropped and counted.
+//
+// Integer formats queue the samples at their resolution: recording int32
+// samples with Int32Handler to PCMS32LE is lossless, unlike through float32.
+type Recorder struct {
+	w      io.WriteSeeker
+	closer io.Closer // file created by CreateRecorder
+
+	codec    Codec
+	channels []int
+	selected [][]float32 // input buffers of the selected channels, reused by the handler
+	floats   [][]float32 // samples converted for ring by the handler
+	ints     [][]int32
+	ring     sampleRing // *RingBuffer[float32] for float formats, else *RingBuffer[int32]
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	// owned by the writing goroutine until done is closed
+	dataSize uint64
+	frames   uint64
+	err      error
+}
+
+// Offsets of sizes in the header written by writeHeader.
+const (
+	wavRIFFSizeOffset = 4
+	wavFactOffset     = 12 + 8 + 40 + 8
+)
+
+// NewRecorder writes a WAV file to w, recording the inputs of a handler with
+// numInputs input channels at sampleRate. Install Handler to record, and Close
+// the recorder when done.
+func NewRecorder(w io.WriteSeeker, sampleRate float64, numInputs int, opts RecorderOptions) (*Recorder, error) {
+	format := opts.Format
+	if format == "" {
+		format = PCMS24LE
+	}
+	t, err := format.SampleType()
+	if err != nil {
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+	codec, err := NewCodec(t)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+	if sampleRate < 1 || sampleRate > math.MaxUint32 {
+		return nil, fmt.Errorf("recorder: invalid sample rate %v", sampleRate)
+	}
+	channels := opts.Channels
+	if channels == nil {
+		channels = make([]int, numInputs)
+		for i := range channels {
+			channels[i] = i
+		}
+	}
+	if len(channels) == 0 || len(channels) > math.MaxUint16 {
+		return nil, fmt.Errorf("recorder: cannot record %d channels", len(channels))
+	}
+	for _, c := range channels {
+		if c < 0 || c >= numInputs {
+			return nil, fmt.Errorf("recorder: channel %d out of %d", c, numInputs)
+		}
+	}
+	if n := bits.OnesCount32(opts.ChannelMask); n > len(channels) {
+		return nil, fmt.Errorf("recorder: channel mask %#x has %d speakers for %d channels", opts.ChannelMask, n, len(channels))
+	}
+	frames := opts.Frames
+	if frames == 0 {
+		frames = int(sampleRate)
+	}
+	if frames < 0 {
+		return nil, fmt.Errorf("recorder: invalid number of frames %d", frames)
+	}
+
+	r := &Recorder{
+		w:        w,
+		codec:    codec,
+		channels: channels,
+		selected: make([][]float32, len(channels)),
+		done:     make(chan struct{}),
+	}
+	if codec.IsFloat() {
+		r.ring = NewRingBuffer[float32](len(channels), frames)
+	} else {
+		r.ring = NewRingBuffer[int32](len(channels), frames)
+	}
+	if err := r.writeHeader(uint32(math.Round(sampleRate)), opts.ChannelMask); err != nil {
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+	go r.run(min(frames, 4096))
+	return r, nil
+}
+
+// CreateRecorder creates a WAV file recording input channels of an opened
+// device, at its current sample rate. Start the device with the recorder's Handler.
+func CreateRecorder(path string, dev *Device, opts RecorderOptions) (*Recorder, error) {
+	if state := dev.State(); state != StatePrepared && state != StateRunning {
+		return nil, &StateError{Op: "CreateRecorder", State: state}
+	}
+	rate, err := dev.GetSampleRate()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+	r, err := NewRecorder(f, rate, len(dev.InputChannels()), opts)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// Handler returns a handler for Device.StartFloat recording the inputs, then
+// calling next if it is not nil.
+func (r *Recorder) Handler(next func(in, out [][]float32)) func(in, out [][]float32) {
+	return func(in, out [][]float32) {
+		r.Record(in)
+		if next != nil {
+			next(in, out)
+		}
+	}
+}
+
+// Int32Handler returns a handler for Device.Start recording the inputs, then
+// calling next if it is not nil. The samples must be scaled to the full range
+// of int32, as Device.Start passes them for ASIOSTInt32LSB channels.
+func (r *Recorder) Int32Handler(next func(in, out [][]int32)) func(in, out [][]int32) {
+	return func(in, out [][]int32) {
+		r.RecordInt32(in)
+		if next != nil {
+			next(in, out)
+		}
+	}
+}
+
+// Record queues the frames of the input buffers in, such as those of a handler,
+// to be written. It does not block, and must not be called concurrently.
+func (r *Recorder) Record(in [][]float32) {
+	switch ring := r.ring.(type) {
+	case *RingBuffer[float32]:
+		for i, c := range r.channels {
+			r.selected[i] = in[c]
+		}
+		ring.Write(r.selected)
+	case *RingBuffer[int32]:
+		r.ints = resizeBufs(r.ints, len(r.channels), numFrames(in))
+		for i, c := range r.channels {
+			for j, x := range in[c] {
+				r.ints[i][j] = quantize(float64(x), r.codec.Bits())
+			}
+		}
+		ring.Write(r.ints)
+	}
+}
+
+// RecordInt32 is like Record, with samples scaled to the full range of int32.
+func (r *Recorder) RecordInt32(in [][]int32) {
+	switch ring := r.ring.(type) {
+	case *RingBuffer[float32]:
+		r.floats = resizeBufs(r.floats, len(r.channels), numFrames(in))
+		for i, c := range r.channels {
+			for j, v := range in[c] {
+				r.floats[i][j] = float32(float64(v) / (1 << 31))
+			}
+		}
+		ring.Write(r.floats)
+	case *RingBuffer[int32]:
+		r.ints = resizeBufs(r.ints, len(r.channels), numFrames(in))
+		for i, c := range r.channels {
+			for j, v := range in[c] {
+				r.ints[i][j] = requantize(v, r.codec.Bits())
+			}
+		}
+		ring.Write(r.ints)
+	}
+}
+
+// Dropped returns the number of frames dropped because the ring buffer was full.
+func (r *Recorder) Dropped() uint64 {
+	return r.ring.Overruns()
+}
+
+// Close writes the queued frames, completes the header and closes the file
+// created by CreateRecorder. Stop recording before. When frames were dropped,
+// the file is still complete, and an error wrapping ErrInputOverflow is returned.
+func (r *Recorder) Close() error {
+	r.closeOnce.Do(func() {
+		r.ring.Close()
+		<-r.done
+		errs := []error{r.err, r.patchHeader()}
+		if r.closer != nil {
+			errs = append(errs, r.closer.Close())
+		}
+		if dropped := r.Dropped(); dropped > 0 {
+			errs = append(errs, fmt.Errorf("%w, %d frames dropped", ErrInputOverflow, dropped))
+		}
+		if err := errors.Join(errs...); err != nil {
+			r.closeErr = fmt.Errorf("recorder: %w", err)
+		}
+	})
+	return r.closeErr
+}
+
+// run writes the queued frames to the file until the ring buffer is closed.
+func (r *Recorder) run(chunk int) {
+	defer close(r.done)
+	switch ring := r.ring.(type) {
+	case *RingBuffer[float32]:
+		writeRecording(r, ring, chunk, r.codec.EncodeFloat32)
+	case *RingBuffer[int32]:
+		writeRecording(r, ring, chunk, r.codec.EncodeInt32)
+	}
+}
+
+// writeRecording is the loop of run for the sample type of ring.
+func writeRecording[T int32 | float32](r *Recorder, ring *RingBuffer[T], chunk int, encode func(dst []byte, src []T) int) {
+	bufs := make([][]T, len(r.channels))
+	for i := range bufs {
+		bufs[i] = make([]T, chunk)
+	}
+	samples := make([]T, chunk*len(r.channels))
+	raw := make([]byte, len(samples)*r.codec.Size())
+	// the sizes of the header must fit 32 bits, with a pad byte
+	maxDataSize := uint64(math.MaxUint32 - wavHeaderSize(r.codec.IsFloat()) - 1)
+
+	for {
+		n, err := ring.ReadWait(context.Background(), bufs)
+		if r.err == nil && n > 0 {
+			samples := samples[:n*len(r.channels)]
+			for i := range n {
+				for j, buf := range bufs {
+					samples[i*len(bufs)+j] = buf[i]
+				}
+			}
+			raw := raw[:len(samples)*r.codec.Size()]
+			encode(raw, samples)
+			if r.dataSize+uint64(len(raw)) > maxDataSize {
+				r.err = errors.New("WAV file size limit reached")
+			} else if _, r.err = r.w.Write(raw); r.err == nil {
+				r.dataSize += uint64(len(raw))
+				r.frames += uint64(n)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wavHeaderSize returns the size of the header up to the data.
+func wavHeaderSize(float bool) int {
+	size := 12 + 8 + 40 + 8 // RIFF, fmt and data chunks
+	if float {
+		size += 12 // fact chunk
+	}
+	return size
+}
+
+// writeHeader writes the header with empty sizes, patched by patchHeader.
+func (r *Recorder) writeHeader(sampleRate, channelMask uint32) error {
+	numChannels := uint16(len(r.channels))
+	size := uint16(r.codec.Size())
+	tag := uint16(waveFormatPCM)
+	if r.codec.IsFloat() {
+		tag = waveFormatIEEEFloat
+	}
+
+	h := make([]byte, 0, wavHeaderSize(r.codec.IsFloat()))
+	h = append(h, "RIFF\x00\x00\x00\x00WAVE"...)
+	h = append(h, "fmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 40)
+	h = binary.LittleEndian.AppendUint16(h, waveFormatExtensible)
+	h = binary.LittleEndian.AppendUint16(h, numChannels)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate*uint32(numChannels*size)) // bytes per second
+	h = binary.LittleEndian.AppendUint16(h, numChannels*size)                    // block align
+	h = binary.LittleEndian.AppendUint16(h, 8*size)                              // bits per sample
+	h = binary.LittleEndian.AppendUint16(h, 22)                                  // size of the extension
+	h = binary.LittleEndian.AppendUint16(h, uint16(r.codec.Bits()))              // valid bits per sample
+	h = binary.LittleEndian.AppendUint32(h, channelMask)
+	h = binary.LittleEndian.AppendUint16(h, tag)
+	h = append(h, waveSubFormatGUID[:]...)
+	if r.codec.IsFloat() {
+		h = append(h, "fact\x04\x00\x00\x00\x00\x00\x00\x00"...)
+	}
+	h = append(h, "data\x00\x00\x00\x00"...)
+	_, err := r.w.Write(h)
+	return err
+}
+
+// patchHeader pads the data to an even size and writes the sizes in the header.
+func (r *Recorder) patchHeader() error {
+	headerSize := uint64(wavHeaderSize(r.codec.IsFloat()))
+	riffSize := headerSize - 8 + r.dataSize
+	if r.dataSize%2 != 0 {
+		if _, err := r.w.Write([]byte{0}); err != nil {
+			return err
+		}
+		riffSize++
+	}
+
+	patch := func(offset int64, v uint64) error {
+		if _, err := r.w.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		return binary.Write(r.w, binary.LittleEndian, uint32(v))
+	}
+	if err := patch(wavRIFFSizeOffset, riffSize); err != nil {
+		return err
+	}
+	if r.codec.IsFloat() {
+		if err := patch(wavFactOffset, r.frames); err != nil {
+			return err
+		}
+	}
+	if err := patch(int64(headerSize-4), r.dataSize); err != nil {
+		return err
+	}
+	_, err := r.w.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,272 @@
+package asio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// wavHeader holds the fields of a WAV file written by a Recorder.
+type wavHeader struct {
+	formatTag, channels, blockAlign, bits, validBits, subFormat uint16
+	sampleRate, channelMask, factFrames                         uint32
+}
+
+type wavFile struct {
+	wavHeader
+	data []byte
+}
+
+// readWAV parses a WAV file, checking the sizes of its chunks.
+func readWAV(t *testing.T, path string) wavFile {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		t.Fatalf("not a WAV file: % x", b[:min(len(b), 12)])
+	}
+	if size := binary.LittleEndian.Uint32(b[4:]); int(size) != len(b)-8 {
+		t.Fatalf("RIFF size %d in a file of %d bytes", size, len(b))
+	}
+
+	var f wavFile
+	le := binary.LittleEndian
+	for rest := b[12:]; len(rest) > 0; {
+		id, size := string(rest[:4]), int(le.Uint32(rest[4:]))
+		body := rest[8 : 8+size]
+		switch id {
+		case "fmt ":
+			if size != 40 {
+				t.Fatalf("fmt chunk of %d bytes", size)
+			}
+			f.formatTag, f.channels = le.Uint16(body), le.Uint16(body[2:])
+			f.sampleRate = le.Uint32(body[4:])
+			if bytesPerSecond := le.Uint32(body[8:]); bytesPerSecond != f.sampleRate*uint32(le.Uint16(body[12:])) {
+				t.Errorf("%d bytes per second", bytesPerSecond)
+			}
+			f.blockAlign, f.bits = le.Uint16(body[12:]), le.Uint16(body[14:])
+			if ext := le.Uint16(body[16:]); ext != 22 {
+				t.Errorf("extension of %d bytes", ext)
+			}
+			f.validBits, f.channelMask, f.subFormat = le.Uint16(body[18:]), le.Uint32(body[20:]), le.Uint16(body[24:])
+			if !bytes.Equal(body[26:], waveSubFormatGUID[:]) {
+				t.Errorf("sub format GUID % x", body[24:])
+			}
+		case "fact":
+			f.factFrames = le.Uint32(body)
+		case "data":
+			f.data = body
+		}
+		rest = rest[8+size+size%2:]
+	}
+	return f
+}
+
+func TestRecorder(t *testing.T) {
+	const bufferSize, steps = 64, 10
+	for _, tc := range []struct {
+		format    PCMFormat
+		subFormat uint16
+		bits      uint16
+	}{
+		{PCMS16LE, waveFormatPCM, 16},
+		{PCMS24LE, waveFormatPCM, 24},
+		{PCMS32LE, waveFormatPCM, 32},
+		{PCMF32LE, waveFormatIEEEFloat, 32},
+	} {
+		sim, sent, _ := newPCMSim(3, bufferSize)
+		device := &Device{}
+		if err := device.LoadDriver(sim); err != nil {
+			t.Fatal(err)
+		}
+		defer device.Unload()
+		if err := device.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "take.wav")
+		rec, err := CreateRecorder(path, device, RecorderOptions{
+			Channels:    []int{2, 0},
+			Format:      tc.format,
+			ChannelMask: SpeakerFrontLeft | SpeakerFrontRight,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handled := 0
+		if err := device.StartFloat(rec.Handler(func(in, out [][]float32) { handled++ })); err != nil {
+			t.Fatal(err)
+		}
+		sim.Step(steps)
+		if err := device.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if handled != steps {
+			t.Errorf("%s: next handler called %d times, want %d", tc.format, handled, steps)
+		}
+
+		f := readWAV(t, path)
+		size := tc.bits / 8
+		if tc.format == PCMS24LE {
+			size = 3
+		}
+		want := wavHeader{
+			formatTag: waveFormatExtensible, channels: 2, blockAlign: 2 * size, bits: 8 * size,
+			validBits: tc.bits, subFormat: tc.subFormat, sampleRate: 44100,
+			channelMask: SpeakerFrontLeft | SpeakerFrontRight,
+		}
+		if tc.format == PCMF32LE {
+			want.factFrames = steps * bufferSize
+		}
+		if f.wavHeader != want {
+			t.Errorf("%s: header %+v, want %+v", tc.format, f.wavHeader, want)
+		}
+
+		in := sent()
+		samples := make([]float32, 0, 2*len(in[0]))
+		for i := range in[0] {
+			samples = append(samples, in[2][i], in[0][i])
+		}
+		raw := make([]byte, len(samples)*int(size))
+		st, _ := tc.format.SampleType()
+		c, _ := NewCodec(st)
+		c.EncodeFloat32(raw, samples)
+		if !bytes.Equal(f.data, raw) {
+			t.Errorf("%s: recorded %d bytes differing from the input", tc.format, len(f.data))
+		}
+	}
+}
+
+func TestRecorderDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mono.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rec, err := NewRecorder(f, 48000, 2, RecorderOptions{Channels: []int{1}, Frames: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an odd number of 24 bit samples is padded; frames not fitting are dropped
+	in := [][]float32{{1, 1, 1, 1, 1, 1, 1}, {0.5, -0.5, 0.25, -0.25, 0, 1, -1}}
+	rec.Handler(nil)(in, nil)
+	if err := rec.Close(); !errors.Is(err, ErrInputOverflow) {
+		t.Errorf("Close() = %v, want input overflow", err)
+	}
+	if rec.Dropped() != 2 {
+		t.Errorf("%d frames dropped, want 2", rec.Dropped())
+	}
+
+	w := readWAV(t, path)
+	want := []byte{0, 0, 0x40, 0, 0, 0xc0, 0, 0, 0x20, 0, 0, 0xe0, 0, 0, 0}
+	if w.channels != 1 || w.sampleRate != 48000 || w.channelMask != 0 || !slices.Equal(w.data, want) {
+		t.Errorf("recorded %+v, want % x", w, want)
+	}
+
+	for _, opts := range []RecorderOptions{
+		{Format: "u8"},
+		{Channels: []int{2}},
+		{Channels: []int{}},
+		{Channels: []int{0}, ChannelMask: SpeakerFrontLeft | SpeakerFrontRight},
+		{Frames: -1},
+	} {
+		if _, err := NewRecorder(f, 48000, 2, opts); err == nil {
+			t.Errorf("recorder with options %+v", opts)
+		}
+	}
+	if _, err := CreateRecorder(filepath.Join(t.TempDir(), "x.wav"), &Device{}, RecorderOptions{}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("recorder of a device without driver: %v", err)
+	}
+}
+
+func TestRecorderInt32(t *testing.T) {
+	// a 32 bit integer driver is recorded to s32le without loss
+	values := []int32{math.MaxInt32, 1, -1, math.MinInt32, 0x12348000, -0x12348000, 0x7fff, 0}
+	sim := NewSimDriver(SimConfig{
+		InputChannels:  2,
+		OutputChannels: 1,
+		MinSize:        len(values),
+		PreferredSize:  len(values),
+		SampleType:     ASIOSTInt32LSB,
+		OnInput: func(_ int, bufs [][]byte) {
+			for c, buf := range bufs {
+				for i, v := range values {
+					binary.LittleEndian.PutUint32(buf[4*i:], uint32(v^int32(c)))
+				}
+			}
+		},
+	})
+	device := &Device{}
+	if err := device.LoadDriver(sim); err != nil {
+		t.Fatal(err)
+	}
+	defer device.Unload()
+	if err := device.Open(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "take.wav")
+	rec, err := CreateRecorder(path, device, RecorderOptions{Format: PCMS32LE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := device.Start(rec.Int32Handler(nil)); err != nil {
+		t.Fatal(err)
+	}
+	sim.Step(1)
+	if err := device.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for _, v := range values {
+		want = binary.LittleEndian.AppendUint32(want, uint32(v))
+		want = binary.LittleEndian.AppendUint32(want, uint32(v^1))
+	}
+	if data := readWAV(t, path).data; !bytes.Equal(data, want) {
+		t.Errorf("recorded % x, want % x", data, want)
+	}
+
+	// other formats round like Record
+	for _, tc := range []struct {
+		format PCMFormat
+		want   []byte
+	}{
+		{PCMS16LE, []byte{0xff, 0x7f, 0, 0, 0, 0, 0, 0x80, 0x35, 0x12, 0xcb, 0xed, 0, 0, 0, 0}},
+		{PCMF32LE, []byte{
+			0, 0, 0x80, 0x3f, 0, 0, 0, 0x30, 0, 0, 0, 0xb0, 0, 0, 0x80, 0xbf,
+			0, 0xa4, 0x11, 0x3e, 0, 0xa4, 0x11, 0xbe, 0, 0xfe, 0x7f, 0x37, 0, 0, 0, 0,
+		}},
+	} {
+		path := filepath.Join(t.TempDir(), "mono.wav")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rec, err := NewRecorder(f, 48000, 1, RecorderOptions{Format: tc.format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.RecordInt32([][]int32{values})
+		if err := rec.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if data := readWAV(t, path).data; !bytes.Equal(data, tc.want) {
+			t.Errorf("%s: recorded % x, want % x", tc.format, data, tc.want)
+		}
+	}
+}